@@ -0,0 +1,195 @@
+package main
+
+import (
+	"encoding/xml"
+	"html"
+	"time"
+
+	"github.com/cipto-hd/snippetbox/internal/models"
+)
+
+// feedSize is the number of snippets in each feed.
+const feedSize = 20
+
+// feedMaxAge is how long feed readers and proxies can cache a feed for.
+const feedMaxAge = 5 * time.Minute
+
+// A feed holds what the Atom and RSS versions of a feed have in common. It's
+// built from a page of snippets by the handler, and then written out in
+// either format by atomFeed() or rssFeed().
+type feed struct {
+	Title   string
+	Link    string // The page which lists the same snippets.
+	Self    string // The URL of the feed itself.
+	Updated time.Time
+	Entries []feedEntry
+}
+
+type feedEntry struct {
+	Snippet *models.Snippet
+	Link    string
+	Author  string
+}
+
+// feedUpdated returns the time that the newest change to any of the snippets
+// was made. An empty feed has never been updated, so it gets the Unix epoch
+// rather than the zero time, which isn't a valid Atom date.
+func feedUpdated(snippets []*models.Snippet) time.Time {
+	updated := time.Unix(0, 0)
+	for _, s := range snippets {
+		if s.Updated.After(updated) {
+			updated = s.Updated
+		}
+	}
+	return updated.UTC()
+}
+
+// feedContent returns the content of a snippet as HTML, for feed readers to
+// show. Readers treat plain text inconsistently, so the content is escaped and
+// put in a <pre> block, which keeps its line breaks and indentation. Escaping
+// the result for XML is left to the encoder.
+func feedContent(s *models.Snippet) string {
+	return "<pre>" + html.EscapeString(s.Content) + "</pre>"
+}
+
+type atomFeedXML struct {
+	XMLName xml.Name       `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string         `xml:"id"`
+	Title   string         `xml:"title"`
+	Updated string         `xml:"updated"`
+	Links   []atomLinkXML  `xml:"link"`
+	Author  atomPersonXML  `xml:"author"`
+	Entries []atomEntryXML `xml:"entry"`
+}
+
+type atomLinkXML struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomPersonXML struct {
+	Name string `xml:"name"`
+}
+
+type atomCategoryXML struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomTextXML struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+type atomEntryXML struct {
+	ID         string            `xml:"id"`
+	Title      string            `xml:"title"`
+	Published  string            `xml:"published"`
+	Updated    string            `xml:"updated"`
+	Link       atomLinkXML       `xml:"link"`
+	Author     atomPersonXML     `xml:"author"`
+	Categories []atomCategoryXML `xml:"category"`
+	Content    atomTextXML       `xml:"content"`
+}
+
+// atomFeed encodes f as an Atom 1.0 document.
+func atomFeed(f *feed) ([]byte, error) {
+	doc := atomFeedXML{
+		ID:      f.Self,
+		Title:   f.Title,
+		Updated: f.Updated.Format(time.RFC3339),
+		Links: []atomLinkXML{
+			{Rel: "self", Type: "application/atom+xml", Href: f.Self},
+			{Rel: "alternate", Type: "text/html", Href: f.Link},
+		},
+		Author: atomPersonXML{Name: "Snippetbox"},
+	}
+	for _, e := range f.Entries {
+		entry := atomEntryXML{
+			ID:        e.Link,
+			Title:     e.Snippet.Title,
+			Published: e.Snippet.Created.UTC().Format(time.RFC3339),
+			Updated:   e.Snippet.Updated.UTC().Format(time.RFC3339),
+			Link:      atomLinkXML{Rel: "alternate", Type: "text/html", Href: e.Link},
+			Author:    atomPersonXML{Name: e.Author},
+			Content:   atomTextXML{Type: "html", Body: feedContent(e.Snippet)},
+		}
+		for _, tag := range e.Snippet.Tags {
+			entry.Categories = append(entry.Categories, atomCategoryXML{Term: tag})
+		}
+		doc.Entries = append(doc.Entries, entry)
+	}
+	return marshalFeed(doc)
+}
+
+type rssFeedXML struct {
+	XMLName xml.Name      `xml:"rss"`
+	Version string        `xml:"version,attr"`
+	AtomNS  string        `xml:"xmlns:atom,attr"`
+	DCNS    string        `xml:"xmlns:dc,attr"`
+	Channel rssChannelXML `xml:"channel"`
+}
+
+type rssChannelXML struct {
+	Title         string       `xml:"title"`
+	Link          string       `xml:"link"`
+	Description   string       `xml:"description"`
+	Self          atomLinkXML  `xml:"atom:link"`
+	LastBuildDate string       `xml:"lastBuildDate"`
+	Items         []rssItemXML `xml:"item"`
+}
+
+type rssGUIDXML struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type rssItemXML struct {
+	Title       string     `xml:"title"`
+	Link        string     `xml:"link"`
+	GUID        rssGUIDXML `xml:"guid"`
+	PubDate     string     `xml:"pubDate"`
+	Creator     string     `xml:"dc:creator"`
+	Categories  []string   `xml:"category"`
+	Description string     `xml:"description"`
+}
+
+// rssFeed encodes f as an RSS 2.0 document. RSS has no author element
+// without an email address, so the Dublin Core creator is used instead.
+func rssFeed(f *feed) ([]byte, error) {
+	doc := rssFeedXML{
+		Version: "2.0",
+		AtomNS:  "http://www.w3.org/2005/Atom",
+		DCNS:    "http://purl.org/dc/elements/1.1/",
+		Channel: rssChannelXML{
+			Title:         f.Title,
+			Link:          f.Link,
+			Description:   f.Title,
+			Self:          atomLinkXML{Rel: "self", Type: "application/rss+xml", Href: f.Self},
+			LastBuildDate: f.Updated.Format(time.RFC1123Z),
+		},
+	}
+	for _, e := range f.Entries {
+		doc.Channel.Items = append(doc.Channel.Items, rssItemXML{
+			Title:       e.Snippet.Title,
+			Link:        e.Link,
+			GUID:        rssGUIDXML{IsPermaLink: true, Value: e.Link},
+			PubDate:     e.Snippet.Created.UTC().Format(time.RFC1123Z),
+			Creator:     e.Author,
+			Categories:  e.Snippet.Tags,
+			Description: feedContent(e.Snippet),
+		})
+	}
+	return marshalFeed(doc)
+}
+
+// marshalFeed encodes doc as an indented XML document. The encoder escapes
+// markup in the text, and replaces any characters which XML doesn't allow, so
+// the content of a snippet can't break the feed.
+func marshalFeed(doc any) ([]byte, error) {
+	out, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), out...), nil
+}
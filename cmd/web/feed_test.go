@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/xml"
+	"testing"
+	"time"
+
+	"github.com/cipto-hd/snippetbox/internal/assert"
+	"github.com/cipto-hd/snippetbox/internal/models"
+)
+
+func TestFeedEscaping(t *testing.T) {
+	updated := time.Date(2024, 3, 17, 10, 15, 0, 0, time.UTC)
+	f := &feed{
+		Title:   "Snippetbox: snippets by <Bob> & co",
+		Link:    "https://snippetbox.example/",
+		Self:    "https://snippetbox.example/feed.atom",
+		Updated: updated,
+		Entries: []feedEntry{{
+			Snippet: &models.Snippet{
+				Title:   "if a < b && c > d",
+				Content: "x := \"<b>&amp;</b>\"\x00\n]]>",
+				Created: updated,
+				Updated: updated,
+			},
+			Link:   "https://snippetbox.example/snippet/view/aNoldsilentP",
+			Author: "Bob & Eve",
+		}},
+	}
+
+	t.Run("Atom", func(t *testing.T) {
+		body, err := atomFeed(f)
+		assert.NilError(t, err)
+		var doc atomFeedXML
+		err = xml.Unmarshal(body, &doc)
+		assert.NilError(t, err)
+		assert.Equal(t, doc.Title, f.Title)
+		assert.Equal(t, doc.Updated, "2024-03-17T10:15:00Z")
+		assert.Equal(t, len(doc.Entries), 1)
+		assert.Equal(t, doc.Entries[0].Title, "if a < b && c > d")
+		assert.Equal(t, doc.Entries[0].Author.Name, "Bob & Eve")
+		// The NUL byte isn't allowed in XML, so it's replaced.
+		assert.Equal(t, doc.Entries[0].Content.Body, "<pre>x := &#34;&lt;b&gt;&amp;amp;&lt;/b&gt;&#34;�\n]]&gt;</pre>")
+	})
+
+	t.Run("RSS", func(t *testing.T) {
+		body, err := rssFeed(f)
+		assert.NilError(t, err)
+		var doc rssFeedXML
+		err = xml.Unmarshal(body, &doc)
+		assert.NilError(t, err)
+		assert.Equal(t, doc.Channel.LastBuildDate, "Sun, 17 Mar 2024 10:15:00 +0000")
+		assert.Equal(t, len(doc.Channel.Items), 1)
+		assert.Equal(t, doc.Channel.Items[0].Title, "if a < b && c > d")
+		assert.Equal(t, doc.Channel.Items[0].Description, "<pre>x := &#34;&lt;b&gt;&amp;amp;&lt;/b&gt;&#34;�\n]]&gt;</pre>")
+	})
+}
@@ -1,22 +1,48 @@
 package main
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"mime"
 	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/julienschmidt/httprouter"
 
+	"github.com/cipto-hd/snippetbox/internal/codeimage"
+	"github.com/cipto-hd/snippetbox/internal/diff"
+	"github.com/cipto-hd/snippetbox/internal/highlight"
 	"github.com/cipto-hd/snippetbox/internal/models"
 	"github.com/cipto-hd/snippetbox/internal/validator"
 )
 
+// homePageSize is the number of snippets listed on each page of the home page.
+const homePageSize = 10
+
 func (app application) showHome(w http.ResponseWriter, r *http.Request) {
 	// Because httprouter matches the "/" path exactly, we can now remove the
 	// manual check of r.URL.Path != "/" from this handler.
 
-	snippets, err := app.Snippet.Latest()
+	// Read the optional "before" and "after" cursors from the query string.
+	// These are produced by the next and previous page links in home.tmpl, so
+	// anything we can't parse is a bad request.
+	q, err := readPageQuery(r, homePageSize)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	page, err := app.Snippet.Latest(q)
 	if err != nil {
 		app.serverError(w, err)
 		return
@@ -24,34 +50,103 @@ func (app application) showHome(w http.ResponseWriter, r *http.Request) {
 
 	// Call the newTemplateData() helper to get a templateData struct containing
 	// the 'default' data (which for now is just the current year), and add the
-	// snippets slice to it.
+	// snippets slice and the page cursors to it.
 	data := app.newTemplateData(r)
-	data.Snippets = snippets
+	data.Snippets = page.Snippets
+	data.Page = page
 
 	// Use the new render helper.
 	app.render(w, http.StatusOK, "home.tmpl", data)
 }
 
 func (app application) showSnippetView(w http.ResponseWriter, r *http.Request) {
-	// When httprouter is parsing a request, the values of any named parameters
-	// will be stored in the request context. We'll talk about request context
-	// in detail later in the book, but for now it's enough to know that you can
-	// use the ParamsFromContext() function to retrieve a slice containing these
-	// parameter names and values like so:
-	params := httprouter.ParamsFromContext(r.Context())
-	// We can then use the ByName() method to get the value of the "id" named
-	// parameter from the slice and validate it as normal.
-	id, err := strconv.Atoi(params.ByName("id"))
+	snippet, ok := app.viewSnippet(w, r, false)
+	if !ok {
+		return
+	}
+	viewerID := app.authenticatedUserID(r)
+
+	data := app.newTemplateData(r)
+	data.Snippet = snippet
+
+	// Fetch the previous versions of the snippet so that readers can browse
+	// its history. The history of a burn-after-reading snippet would reveal
+	// its content without counting a view, so only its owner gets to see it.
+	if snippet.MaxViews == 0 || snippet.UserID == viewerID {
+		revisions, err := app.Snippet.Revisions(snippet.ID)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+		data.Revisions = revisions
+	}
+	// Link to the snippet this one was forked from, if the viewer can still
+	// see it, and count the forks made from this one.
+	if snippet.ParentID != 0 {
+		parent, err := app.Snippet.Get(snippet.ParentID, viewerID)
+		if err != nil && !errors.Is(err, models.ErrNoRecord) {
+			app.serverError(w, err)
+			return
+		}
+		data.Parent = parent
+	}
+	forks, err := app.Snippet.Forks(snippet.ID, viewerID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	data.Forks = forks
+
+	// Make sure that a burn-after-reading snippet can't be seen again from a
+	// cache once its views are used up.
+	if snippet.MaxViews > 0 {
+		w.Header().Set("Cache-Control", "no-store")
+	}
+
+	// Use the new render helper.
+	app.render(w, http.StatusOK, "view.tmpl", data)
+}
 
-	// id, err := strconv.Atoi(r.URL.Query().Get("id"))
-	if err != nil || id < 1 {
+// showSnippetRaw serves the exact content of a snippet as plain text, so that
+// it can be fetched with curl or pasted into an editor.
+func (app *application) showSnippetRaw(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.viewSnippet(w, r, true)
+	if !ok {
+		return
+	}
+	serveSnippetContent(w, r, snippet)
+}
+
+// showSnippetDownload serves the content of a snippet's main file as an
+// attachment, under the file's name.
+func (app *application) showSnippetDownload(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.viewSnippet(w, r, true)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+		"filename": downloadFilename(snippet),
+	}))
+	serveSnippetContent(w, r, snippet)
+}
+
+// showSnippetEmbed renders just the highlighted code of a snippet, in the bare
+// "frame" layout, for showing in a frame on another site. The allowFraming
+// middleware sets which sites can do that.
+func (app *application) showSnippetEmbed(w http.ResponseWriter, r *http.Request) {
+	slug := httprouter.ParamsFromContext(r.Context()).ByName("slug")
+	if app.redirectNumericID(w, r, slug) {
+		return
+	}
+	if !models.IsSlug(slug) {
 		app.notFound(w)
 		return
 	}
-	// Use the SnippetModel object's Get method to retrieve the data for a
-	// specific record based on its ID. If no matching record is found,
-	// return a 404 Not Found response.
-	snippet, err := app.Snippet.Get(id)
+	// Embeds are shown as an anonymous visitor would see them, since the
+	// page they're on may be seen by anyone, so private snippets are never
+	// found. Nor are burn-after-reading snippets, because every load of the
+	// page would use up a view.
+	snippet, err := app.Snippet.GetBySlug(slug, 0)
 	if err != nil {
 		if errors.Is(err, models.ErrNoRecord) {
 			app.notFound(w)
@@ -60,12 +155,436 @@ func (app application) showSnippetView(w http.ResponseWriter, r *http.Request) {
 		}
 		return
 	}
+	if snippet.MaxViews > 0 {
+		app.notFound(w)
+		return
+	}
+
+	// There's no session here, so the template data is built directly
+	// rather than with newTemplateData().
+	data := &templateData{
+		CurrentYear: time.Now().Year(),
+		Snippet:     snippet,
+		BaseURL:     app.baseURL,
+	}
+	w.Header().Set("Cache-Control", snippetCacheControl(snippet, time.Now()))
+	app.renderLayout(w, http.StatusOK, "embed.tmpl", "frame", data)
+}
+
+// oEmbedResponse is the JSON served by the oEmbed endpoint, as described at
+// https://oembed.com. Snippets are "rich" content, shown with the embed page.
+type oEmbedResponse struct {
+	Type         string `json:"type"`
+	Version      string `json:"version"`
+	Title        string `json:"title"`
+	AuthorName   string `json:"author_name,omitempty"`
+	ProviderName string `json:"provider_name"`
+	ProviderURL  string `json:"provider_url"`
+	HTML         string `json:"html"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+}
+
+// oEmbedWidth is the width in pixels of the frame offered by the oEmbed
+// endpoint, unless the consumer asks for a smaller one.
+const oEmbedWidth = 600
+
+// oEmbedPathRX matches the paths of the snippet URLs which the oEmbed
+// endpoint will describe, capturing the slug.
+var oEmbedPathRX = regexp.MustCompile(`^/snippet/(?:view|embed)/([^/]+)$`)
+
+// oEmbedSize returns size, or the consumer's maxwidth or maxheight if that's
+// given and is smaller.
+func oEmbedSize(limit string, size int) (int, error) {
+	if limit == "" {
+		return size, nil
+	}
+	n, err := strconv.Atoi(limit)
+	if err != nil {
+		return 0, err
+	}
+	if n < 1 {
+		return 0, fmt.Errorf("invalid size %d", n)
+	}
+	return min(size, n), nil
+}
+
+// showOEmbed describes a snippet for oEmbed consumers, such as chat apps and
+// wikis, which are given the snippet's URL in the url query parameter. Only
+// JSON is supported. Snippets are described as an anonymous visitor would see
+// them, and only if they are previewable; for anything else the response is
+// 404 Not Found, so as not to give away that the snippet exists.
+func (app *application) showOEmbed(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if format := query.Get("format"); format != "" && format != "json" {
+		app.clientError(w, http.StatusNotImplemented)
+		return
+	}
+	width, err := oEmbedSize(query.Get("maxwidth"), oEmbedWidth)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	height, err := oEmbedSize(query.Get("maxheight"), embedHeight)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	u, err := url.Parse(query.Get("url"))
+	if err != nil || u.Scheme+"://"+u.Host != app.baseURL {
+		app.notFound(w)
+		return
+	}
+	match := oEmbedPathRX.FindStringSubmatch(u.Path)
+	if match == nil || !models.IsSlug(match[1]) {
+		app.notFound(w)
+		return
+	}
+	snippet, err := app.Snippet.GetBySlug(match[1], 0)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+	if !previewable(snippet) {
+		app.notFound(w)
+		return
+	}
+
+	resp := oEmbedResponse{
+		Type:         "rich",
+		Version:      "1.0",
+		Title:        snippet.Title,
+		ProviderName: "Snippetbox",
+		ProviderURL:  app.baseURL + "/",
+		HTML:         iframeHTML(app.baseURL, snippet, strconv.Itoa(width), height),
+		Width:        width,
+		Height:       height,
+	}
+	user, err := app.User.Get(snippet.UserID)
+	if err != nil && !errors.Is(err, models.ErrNoRecord) {
+		app.serverError(w, err)
+		return
+	}
+	if user != nil {
+		resp.AuthorName = user.Name
+	}
+	js, err := json.Marshal(resp)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", snippetCacheControl(snippet, time.Now()))
+	w.Write(js)
+}
+
+// showSnippetZip serves all of the files in a snippet as a zip archive.
+func (app *application) showSnippetZip(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.viewSnippet(w, r, true)
+	if !ok {
+		return
+	}
+	// Build the archive in memory first, so that an error can still be
+	// reported properly.
+	var buf bytes.Buffer
+	err := writeSnippetZip(&buf, snippet)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	name := snippetFilename(snippet)
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+		"filename": strings.TrimSuffix(name, path.Ext(name)) + ".zip",
+	}))
+	w.Header().Set("Cache-Control", snippetCacheControl(snippet, time.Now()))
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	buf.WriteTo(w)
+}
+
+// showSnippetQR serves a QR code for the URL of a snippet, as a PNG, or as an
+// SVG if the format query parameter is "svg", so that it can be opened on a
+// phone without typing the URL. The snippet is looked up rather than viewed,
+// so making a code for a burn-after-reading snippet doesn't use up a view.
+func (app *application) showSnippetQR(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "png" && format != "svg" {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	slug := httprouter.ParamsFromContext(r.Context()).ByName("slug")
+	if app.redirectNumericID(w, r, slug) {
+		return
+	}
+	if !models.IsSlug(slug) {
+		app.notFound(w)
+		return
+	}
+	snippet, err := app.Snippet.GetBySlug(slug, app.authenticatedUserID(r))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	code, err := qrCode(app.baseURL + "/snippet/view/" + snippet.Slug)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	body, contentType := code.PNG(), "image/png"
+	if format == "svg" {
+		body, contentType = qrSVG(code), "image/svg+xml"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", snippetCacheControl(snippet, time.Now()))
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.Write(body)
+}
+
+// showSnippetImage serves a PNG image of one of the files of a snippet, the
+// main file unless the file query parameter gives another's number, for
+// pasting into slides and chat. The theme query parameter picks the colours,
+// and lines=false leaves out the line numbers. Images are cached by a hash of
+// everything that goes into them, which is also their ETag.
+func (app *application) showSnippetImage(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	theme := codeimage.Light
+	if name := query.Get("theme"); name != "" {
+		theme = codeimage.LookupTheme(name)
+		if theme == nil {
+			app.clientError(w, http.StatusBadRequest)
+			return
+		}
+	}
+	lineNumbers := true
+	if v := query.Get("lines"); v != "" {
+		var err error
+		lineNumbers, err = strconv.ParseBool(v)
+		if err != nil {
+			app.clientError(w, http.StatusBadRequest)
+			return
+		}
+	}
+	n, err := parseFileNumber(query.Get("file"))
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	snippet, ok := app.viewSnippet(w, r, true)
+	if !ok {
+		return
+	}
+	opts, ok := codeImageOptions(snippet, n, theme, lineNumbers)
+	if !ok {
+		app.notFound(w)
+		return
+	}
+	img, key, err := app.codeImages.Render(opts)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("ETag", `"`+key+`"`)
+	w.Header().Set("Cache-Control", snippetCacheControl(snippet, time.Now()))
+	http.ServeContent(w, r, "", snippet.Updated, bytes.NewReader(img))
+}
+
+// showTag lists the snippets carrying a tag, newest first, with the same
+// cursor-based pagination as the home page.
+func (app *application) showTag(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+	tag := params.ByName("name")
+	if !validator.Matches(tag, validator.TagRX) {
+		app.notFound(w)
+		return
+	}
+
+	q, err := readPageQuery(r, homePageSize)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	page, err := app.Snippet.ByTag(tag, q)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
 
-	// And do the same thing again here...
 	data := app.newTemplateData(r)
-	data.Snippet = snippet
+	data.Tag = tag
+	data.Snippets = page.Snippets
+	data.Page = page
+	app.render(w, http.StatusOK, "tag.tmpl", data)
+}
 
-	// Use the new render helper.
+// showFeed serves the latest snippets as an Atom feed at /feed.atom, or as an
+// RSS feed at /feed.rss. The "tag" or "author" query parameter narrows it to
+// the snippets with that tag, or by that user.
+func (app *application) showFeed(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	tag, author := query.Get("tag"), query.Get("author")
+	q := models.PageQuery{Limit: feedSize}
+	f := &feed{
+		Title: "Snippetbox",
+		Link:  app.baseURL + "/",
+		Self:  app.baseURL + r.URL.Path,
+	}
+
+	var page *models.Page
+	var err error
+	switch {
+	case tag != "" && author != "":
+		app.clientError(w, http.StatusBadRequest)
+		return
+	case tag != "":
+		if !validator.Matches(tag, validator.TagRX) {
+			app.notFound(w)
+			return
+		}
+		f.Title = "Snippetbox: snippets tagged " + tag
+		f.Link = app.baseURL + "/tag/" + tag
+		f.Self += "?" + url.Values{"tag": {tag}}.Encode()
+		page, err = app.Snippet.ByTag(tag, q)
+	case author != "":
+		var id int
+		var user *models.User
+		id, err = strconv.Atoi(author)
+		if err != nil || id < 1 {
+			app.notFound(w)
+			return
+		}
+		user, err = app.User.Get(id)
+		if err != nil {
+			if errors.Is(err, models.ErrNoRecord) {
+				app.notFound(w)
+			} else {
+				app.serverError(w, err)
+			}
+			return
+		}
+		f.Title = "Snippetbox: snippets by " + user.Name
+		f.Self += "?author=" + strconv.Itoa(id)
+		page, err = app.Snippet.ByAuthor(id, q)
+	default:
+		page, err = app.Snippet.Latest(q)
+	}
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	// Look up each author once, however many of their snippets are listed.
+	authors := map[int]string{}
+	for _, s := range page.Snippets {
+		name, ok := authors[s.UserID]
+		if !ok {
+			user, err := app.User.Get(s.UserID)
+			if err != nil && !errors.Is(err, models.ErrNoRecord) {
+				app.serverError(w, err)
+				return
+			}
+			name = "Anonymous"
+			if user != nil {
+				name = user.Name
+			}
+			authors[s.UserID] = name
+		}
+		f.Entries = append(f.Entries, feedEntry{
+			Snippet: s,
+			Link:    app.baseURL + "/snippet/view/" + s.Slug,
+			Author:  name,
+		})
+	}
+	f.Updated = feedUpdated(page.Snippets)
+
+	encode, contentType := atomFeed, "application/atom+xml"
+	if path.Ext(r.URL.Path) == ".rss" {
+		encode, contentType = rssFeed, "application/rss+xml"
+	}
+	body, err := encode(f)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	// The ETag covers the whole document, so that it changes when a snippet
+	// is deleted as well as when one is added or edited.
+	sum := sha256.Sum256(body)
+	w.Header().Set("Content-Type", contentType+"; charset=utf-8")
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(feedMaxAge.Seconds())))
+	http.ServeContent(w, r, "", f.Updated, bytes.NewReader(body))
+}
+
+// showSnippetRevision renders a previous version of a snippet at the stable
+// URL /snippet/view/:slug/revision/:number.
+func (app *application) showSnippetRevision(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+	number, err := strconv.Atoi(params.ByName("number"))
+	if err != nil || number < 1 {
+		app.notFound(w)
+		return
+	}
+	slug := params.ByName("slug")
+	if app.redirectNumericID(w, r, slug) {
+		return
+	}
+	if !models.IsSlug(slug) {
+		app.notFound(w)
+		return
+	}
+
+	viewerID := app.authenticatedUserID(r)
+	snippet, err := app.Snippet.GetBySlug(slug, viewerID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+	if snippet.MaxViews > 0 && snippet.UserID != viewerID {
+		app.notFound(w)
+		return
+	}
+	revision, err := app.Snippet.GetRevision(snippet.ID, number, viewerID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+	revisions, err := app.Snippet.Revisions(snippet.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	// Show the revision's title and files in place of the current ones, so
+	// that view.tmpl can render it like any other snippet.
+	old := *snippet
+	old.Title = revision.Title
+	old.Files = revisionFiles(snippet, revision)
+	old.Content = old.Files[0].Content
+	old.Language = old.Files[0].Language
+
+	data := app.newTemplateData(r)
+	data.Snippet = &old
+	data.Revision = revision
+	data.Revisions = revisions
 	app.render(w, http.StatusOK, "view.tmpl", data)
 }
 
@@ -75,12 +594,176 @@ func (app application) showSnippetView(w http.ResponseWriter, r *http.Request) {
 // must be exported in order to be read by the html/template package when
 // rendering the template.
 type snippetCreateForm struct {
-	Title               string `form:"title"`
-	Content             string `form:"content"`
-	Expires             int    `form:"expires"`
+	Title               string            `form:"title"`
+	Files               []snippetFileForm `form:"files"`
+	Action              string            `form:"action"`
+	Visibility          models.Visibility `form:"visibility"`
+	ExpiresMode         string            `form:"expiresMode"`
+	ExpiresIn           int               `form:"expiresIn"`
+	ExpiresUnit         string            `form:"expiresUnit"`
+	ExpiresAt           string            `form:"expiresAt"`
+	Parent              string            `form:"parent"`
+	MaxViews            int               `form:"maxViews"`
+	Tags                string            `form:"tags"`
+	ConfirmSecrets      string            `form:"confirmSecrets"`
 	validator.Validator `form:"-"`
 }
 
+// snippetFileForm holds the fields for one of the files on the create and edit
+// forms. They are posted as files[0].name, files[0].content and so on.
+type snippetFileForm struct {
+	Name     string `form:"name"`
+	Language string `form:"language"`
+	Content  string `form:"content"`
+}
+
+// Limits on the files in a snippet. The limits on their size are set by the
+// -max-file-size and -max-snippet-size flags.
+const (
+	maxFiles         = 10
+	maxFilenameChars = 100
+)
+
+// The actions of the add and remove file buttons. The remove action is
+// followed by the index of the file, like "remove-2".
+const (
+	addFileAction    = "add"
+	removeFileAction = "remove-"
+)
+
+// maxBurnViews is the largest number of views a burn-after-reading snippet
+// can be given.
+const maxBurnViews = 100
+
+// The ways of choosing a snippet's expiry on the create and edit forms.
+const (
+	expiresIn    = "in"    // A number of minutes, hours or days from now.
+	expiresNever = "never" // The snippet never expires.
+	expiresAt    = "at"    // An explicit date and time, in UTC.
+	expiresKeep  = "keep"  // Leave the expiry as it is (edit form only).
+)
+
+// expiryUnits maps the units offered for the expiresIn mode to their
+// durations.
+var expiryUnits = map[string]time.Duration{
+	"minutes": time.Minute,
+	"hours":   time.Hour,
+	"days":    24 * time.Hour,
+}
+
+// maxExpiry is how far into the future a snippet's expiry can be set.
+const maxExpiry = 10 * 365 * 24 * time.Hour
+
+// expiresAtLayout is the format of the value of a datetime-local input.
+const expiresAtLayout = "2006-01-02T15:04"
+
+// validateExpiry() checks the expiry fields and returns the expiry time they
+// ask for, relative to now. The zero time means the snippet never expires.
+func (form *snippetCreateForm) validateExpiry(now time.Time) time.Time {
+	var expires time.Time
+	switch form.ExpiresMode {
+	case expiresNever:
+		return time.Time{}
+	case expiresIn:
+		unit, ok := expiryUnits[form.ExpiresUnit]
+		if !ok {
+			form.AddFieldError("expires", "Please choose minutes, hours or days")
+			return time.Time{}
+		}
+		// Check the range before multiplying, so that huge values can't
+		// overflow the duration.
+		if !validator.InRange(form.ExpiresIn, 1, int(maxExpiry/unit)) {
+			form.AddFieldError("expires", "This must be between now and 10 years from now")
+			return time.Time{}
+		}
+		expires = now.Add(time.Duration(form.ExpiresIn) * unit)
+	case expiresAt:
+		t, err := time.Parse(expiresAtLayout, form.ExpiresAt)
+		form.CheckField(err == nil, "expires", "This field must be a valid date and time")
+		form.CheckField(t.After(now) && t.Sub(now) <= maxExpiry, "expires", "This must be between now and 10 years from now")
+		expires = t
+	default:
+		form.AddFieldError("expires", "Please choose when the snippet expires")
+	}
+	return expires
+}
+
+// validateTitleAndFiles() runs the checks shared by the create and edit
+// forms, limiting each file to maxFileSize bytes and all of them together to
+// maxSnippetSize. Files without a name are given one like "file2.go".
+func (form *snippetCreateForm) validateTitleAndFiles(maxFileSize, maxSnippetSize int) {
+	form.CheckField(validator.NotBlank(form.Title), "title", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Title, 100), "title", "This field cannot be more than 100 characters long")
+	if len(form.Files) == 0 {
+		form.AddFieldError("files", "A snippet must have at least one file")
+		return
+	}
+	form.CheckField(validator.MaxItems(form.Files, maxFiles), "files", fmt.Sprintf("A snippet cannot have more than %d files", maxFiles))
+
+	size := 0
+	names := make(map[string]bool, len(form.Files))
+	for i := range form.Files {
+		f := &form.Files[i]
+		key := fmt.Sprintf("files.%d.", i)
+		f.Name = strings.TrimSpace(f.Name)
+		if f.Name == "" {
+			f.Name = defaultFilename(i, f.Language)
+		}
+		form.CheckField(validator.MaxChars(f.Name, maxFilenameChars), key+"name", fmt.Sprintf("This field cannot be more than %d characters long", maxFilenameChars))
+		form.CheckField(validator.Matches(f.Name, validator.FilenameRX), key+"name", "Filenames may only contain letters, digits and the characters . _ - and cannot start with a dot")
+		// Names are compared ignoring case, since the files may be unpacked
+		// onto a case-insensitive filesystem.
+		form.CheckField(!names[strings.ToLower(f.Name)], key+"name", "Each file must have a different name")
+		names[strings.ToLower(f.Name)] = true
+		form.CheckField(validator.PermittedValue(f.Language, highlight.Names()...), key+"language", "Please choose one of the listed languages")
+		form.CheckField(validator.NotBlank(f.Content), key+"content", "This field cannot be blank")
+		form.CheckField(len(f.Content) <= maxFileSize, key+"content", "This file cannot be more than "+formatSize(maxFileSize))
+		size += len(f.Content)
+	}
+	form.CheckField(size <= maxSnippetSize, "files", "The files cannot be more than "+formatSize(maxSnippetSize)+" in total")
+}
+
+// editFiles() carries out the add and remove file buttons on the create and
+// edit forms, which submit the form with an action instead of saving it. It
+// reports whether one of them was pressed, in which case the form should be
+// shown again.
+func (form *snippetCreateForm) editFiles() bool {
+	switch {
+	case form.Action == addFileAction:
+		if len(form.Files) < maxFiles {
+			form.Files = append(form.Files, snippetFileForm{Language: highlight.Text.Name})
+		}
+	case strings.HasPrefix(form.Action, removeFileAction):
+		i, err := strconv.Atoi(strings.TrimPrefix(form.Action, removeFileAction))
+		if err == nil && i >= 0 && i < len(form.Files) && len(form.Files) > 1 {
+			form.Files = slices.Delete(form.Files, i, i+1)
+		}
+	default:
+		return false
+	}
+	form.Action = ""
+	return true
+}
+
+// files() returns the files on the form, ready to be saved.
+func (form *snippetCreateForm) files() []*models.File {
+	files := make([]*models.File, len(form.Files))
+	for i, f := range form.Files {
+		files[i] = &models.File{Name: f.Name, Language: f.Language, Content: f.Content}
+	}
+	return files
+}
+
+// fileForms returns the files of an existing snippet for pre-filling the
+// create and edit forms.
+func fileForms(s *models.Snippet) []snippetFileForm {
+	var forms []snippetFileForm
+	for _, f := range snippetFiles(s) {
+		forms = append(forms, snippetFileForm{Name: f.Name, Language: f.Language, Content: f.Content})
+	}
+	return forms
+}
+
 // Add a new snippetCreate handler, which for now returns a placeholder
 // response. We'll update this shortly to show a HTML form.
 func (app *application) showSnippetCreate(w http.ResponseWriter, r *http.Request) {
@@ -90,7 +773,11 @@ func (app *application) showSnippetCreate(w http.ResponseWriter, r *http.Request
 	// 'initial' values for the form --- here we set the initial value for the
 	// snippet expiry to 365 days.
 	data.Form = snippetCreateForm{
-		Expires: 365,
+		Files:       []snippetFileForm{{Language: highlight.Text.Name}},
+		Visibility:  models.VisibilityPublic,
+		ExpiresMode: expiresIn,
+		ExpiresIn:   365,
+		ExpiresUnit: "days",
 	}
 	app.render(w, http.StatusOK, "create.tmpl", data)
 }
@@ -148,6 +835,15 @@ func (app application) doSnippetCreate(w http.ResponseWriter, r *http.Request) {
 
 	/* Form decoding end */
 
+	// The add and remove file buttons just show the form again with the
+	// files changed.
+	if form.editFiles() {
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, http.StatusOK, "create.tmpl", data)
+		return
+	}
+
 	/* data validation start */
 	// Because the Validator type is embedded by the snippetCreateForm struct,
 	// we can call CheckField() directly on it to execute our validation checks.
@@ -156,14 +852,38 @@ func (app application) doSnippetCreate(w http.ResponseWriter, r *http.Request) {
 	// the first line here we "check that the form.Title field is not blank". In
 	// the second, we "check that the form.Title field has a maximum character
 	// length of 100" and so on.
-	form.CheckField(validator.NotBlank(form.Title), "title", "This field cannot be blank")
-	form.CheckField(validator.MaxChars(form.Title, 100), "title", "This field cannot be more than 100 characters long")
-	form.CheckField(validator.NotBlank(form.Content), "content", "This field cannot be blank")
+	form.validateTitleAndFiles(app.maxFileSize, app.maxSnippetSize)
 
-	// form.CheckField(validator.PermittedInt(form.Expires, 1, 7, 365), "expires", "This field must equal 1, 7 or 365")
-	// Use the generic PermittedValue() function instead of the type-specific
-	// PermittedInt() function.
-	form.CheckField(validator.PermittedValue(form.Expires, 1, 7, 365), "expires", "This field must equal 1, 7 or 365")
+	// Work out when the snippet expires from whichever of the expiry options
+	// was chosen.
+	expires := form.validateExpiry(time.Now())
+
+	form.CheckField(validator.InRange(form.MaxViews, 0, maxBurnViews), "maxViews", fmt.Sprintf("This field must be between 0 and %d", maxBurnViews))
+	form.CheckField(validator.PermittedValue(form.Visibility, models.Visibilities...), "visibility", "This field must equal public, unlisted or private")
+
+	// Split the tags field into individual tags, and check each of them.
+	tags := parseTags(form.Tags)
+	form.CheckField(validator.MaxItems(tags, 10), "tags", "You can add at most 10 tags")
+	form.CheckField(validator.All(tags, func(tag string) bool {
+		return validator.MaxChars(tag, 30)
+	}), "tags", "Each tag cannot be more than 30 characters long")
+	form.CheckField(validator.All(tags, func(tag string) bool {
+		return validator.Matches(tag, validator.TagRX)
+	}), "tags", "Tags may only contain letters, digits and the characters . + _ -")
+
+	// A fork records the snippet it was copied from. Check that the parent is
+	// still there, and that this user is still allowed to fork it.
+	userID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+	var parent *models.Snippet
+	if form.Parent != "" {
+		parent, err = app.forkableSnippet(form.Parent, userID)
+		if errors.Is(err, models.ErrNoRecord) {
+			form.AddNonFieldError("The snippet you are forking is no longer available")
+		} else if err != nil {
+			app.serverError(w, err)
+			return
+		}
+	}
 
 	// If there are any validation errors re-display the create.tmpl template,
 	// passing in the snippetCreateForm instance as dynamic data in the Form
@@ -172,14 +892,40 @@ func (app application) doSnippetCreate(w http.ResponseWriter, r *http.Request) {
 	if !form.Valid() {
 		data := app.newTemplateData(r)
 		data.Form = form
+		data.Parent = parent
 		app.render(w, http.StatusUnprocessableEntity, "create.tmpl", data)
 		return
 	}
 	/* data validation end */
 
-	// Pass the data to the SnippetModel.Insert() method, receiving the
-	// ID of the new record back.
-	id, err := app.Snippet.Insert(form.Title, form.Content, form.Expires)
+	// Check for secrets, like keys and passwords, before anything is saved.
+	// They're shown to the user, who can remove them or confirm that they're
+	// meant to be there, unless the -block-secrets flag says they have to go.
+	if warning := app.scanSecrets(&form); warning != nil && (warning.Blocking || form.ConfirmSecrets != warning.Fingerprint) {
+		data := app.newTemplateData(r)
+		data.Form = form
+		data.Parent = parent
+		data.Secrets = warning
+		app.render(w, http.StatusUnprocessableEntity, "create.tmpl", data)
+		return
+	}
+
+	// Pass the data to the SnippetModel.Insert() method, along with the ID of
+	// the logged-in user as the snippet owner. Insert() fills in the ID and
+	// slug of the new record.
+	snippet := &models.Snippet{
+		UserID:     userID,
+		Title:      form.Title,
+		Files:      form.files(),
+		Visibility: form.Visibility,
+		Expires:    expires,
+		Tags:       tags,
+		MaxViews:   form.MaxViews,
+	}
+	if parent != nil {
+		snippet.ParentID = parent.ID
+	}
+	_, err = app.Snippet.Insert(snippet)
 	if err != nil {
 		app.serverError(w, err)
 		return
@@ -190,7 +936,290 @@ func (app application) doSnippetCreate(w http.ResponseWriter, r *http.Request) {
 	app.sessionManager.Put(r.Context(), "flash", "Snippet successfully created!")
 
 	// Redirect the user to the relevant page for the snippet.
-	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%d", id), http.StatusSeeOther)
+	http.Redirect(w, r, "/snippet/view/"+snippet.Slug, http.StatusSeeOther)
+}
+
+// showSnippetFork displays the create form pre-filled with a copy of an
+// existing snippet. Submitting it creates a new snippet owned by the
+// logged-in user, which records the original as its parent.
+func (app *application) showSnippetFork(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+	parent, err := app.forkableSnippet(params.ByName("slug"), app.authenticatedUserID(r))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Parent = parent
+	data.Form = snippetCreateForm{
+		Title:       parent.Title,
+		Files:       fileForms(parent),
+		Visibility:  parent.Visibility,
+		Tags:        strings.Join(parent.Tags, " "),
+		ExpiresMode: expiresIn,
+		ExpiresIn:   365,
+		ExpiresUnit: "days",
+		Parent:      parent.Slug,
+	}
+	app.render(w, http.StatusOK, "create.tmpl", data)
+}
+
+// showSnippetForks lists the forks of a snippet which the viewer may see.
+func (app *application) showSnippetForks(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+	slug := params.ByName("slug")
+	if app.redirectNumericID(w, r, slug) {
+		return
+	}
+	if !models.IsSlug(slug) {
+		app.notFound(w)
+		return
+	}
+	viewerID := app.authenticatedUserID(r)
+	snippet, err := app.Snippet.GetBySlug(slug, viewerID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+	forks, err := app.Snippet.Forks(snippet.ID, viewerID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Snippet = snippet
+	data.Snippets = forks
+	app.render(w, http.StatusOK, "forks.tmpl", data)
+}
+
+// showSnippetEdit displays the edit form for a snippet, pre-filled with its
+// current title and content. Only the owner of the snippet may edit it.
+func (app *application) showSnippetEdit(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.ownedSnippet(w, r)
+	if !ok {
+		return
+	}
+	data := app.newTemplateData(r)
+	data.Snippet = snippet
+	data.Form = snippetCreateForm{
+		Title:       snippet.Title,
+		Files:       fileForms(snippet),
+		ExpiresMode: expiresKeep,
+		ExpiresIn:   7,
+		ExpiresUnit: "days",
+	}
+	app.render(w, http.StatusOK, "edit.tmpl", data)
+}
+
+func (app *application) doSnippetEdit(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.ownedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	var form snippetCreateForm
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	if form.editFiles() {
+		data := app.newTemplateData(r)
+		data.Snippet = snippet
+		data.Form = form
+		app.render(w, http.StatusOK, "edit.tmpl", data)
+		return
+	}
+
+	// Reuse the create form's title and file checks, and its expiry checks
+	// too unless the owner chose to keep the current expiry.
+	form.validateTitleAndFiles(app.maxFileSize, app.maxSnippetSize)
+	var expires *time.Time
+	if form.ExpiresMode != expiresKeep {
+		t := form.validateExpiry(time.Now())
+		expires = &t
+	}
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Snippet = snippet
+		data.Form = form
+		app.render(w, http.StatusUnprocessableEntity, "edit.tmpl", data)
+		return
+	}
+
+	// Check for secrets as on the create form, so that they can't be edited
+	// into a snippet after it's been published.
+	if warning := app.scanSecrets(&form); warning != nil && (warning.Blocking || form.ConfirmSecrets != warning.Fingerprint) {
+		data := app.newTemplateData(r)
+		data.Snippet = snippet
+		data.Form = form
+		data.Secrets = warning
+		app.render(w, http.StatusUnprocessableEntity, "edit.tmpl", data)
+		return
+	}
+
+	err = app.Snippet.Update(snippet.ID, snippet.UserID, form.Title, form.files(), expires)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Snippet successfully updated!")
+	http.Redirect(w, r, "/snippet/view/"+snippet.Slug, http.StatusSeeOther)
+}
+
+// doSnippetDelete moves a snippet owned by the logged-in user into their
+// trash, from where it can be restored until it's purged.
+func (app *application) doSnippetDelete(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.ownedSnippet(w, r)
+	if !ok {
+		return
+	}
+	err := app.Snippet.Delete(snippet.ID, snippet.UserID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+	app.sessionManager.Put(r.Context(), "flash", "Snippet moved to the trash.")
+	http.Redirect(w, r, "/account/snippets", http.StatusSeeOther)
+}
+
+// Define a searchForm struct to hold the search query string parameters.
+type searchForm struct {
+	Query               string `form:"q"`
+	Mine                bool   `form:"mine"`
+	Page                int    `form:"page"`
+	validator.Validator `form:"-"`
+}
+
+func (app *application) showSearch(w http.ResponseWriter, r *http.Request) {
+	// The search form is submitted with GET, so that result pages can be
+	// bookmarked and shared. That means we decode from the URL query rather
+	// than using decodePostForm().
+	var form searchForm
+	err := app.formDecoder.Decode(&form, r.URL.Query())
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	form.Query = strings.TrimSpace(form.Query)
+	form.CheckField(validator.MaxChars(form.Query, 200), "q", "This field cannot be more than 200 characters long")
+
+	data := app.newTemplateData(r)
+	// With no query there's nothing to search for yet, so just show the form.
+	if form.Query == "" || !form.Valid() {
+		data.Form = form
+		status := http.StatusOK
+		if !form.Valid() {
+			status = http.StatusUnprocessableEntity
+		}
+		app.render(w, status, "search.tmpl", data)
+		return
+	}
+
+	filters := models.SearchFilters{ViewerID: data.AuthenticatedUserID}
+	if form.Mine && data.IsAuthenticated {
+		filters.UserID = data.AuthenticatedUserID
+	}
+	results, err := app.Snippet.Search(form.Query, filters, form.Page)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data.Form = form
+	data.Search = results
+	app.render(w, http.StatusOK, "search.tmpl", data)
+}
+
+// Define a diffForm struct to hold the diff page's query string parameters.
+// From and To are references to the two versions being compared, as accepted
+// by findDiffSource().
+type diffForm struct {
+	From                string `form:"from"`
+	To                  string `form:"to"`
+	View                string `form:"view"`
+	IgnoreWhitespace    bool   `form:"ws"`
+	validator.Validator `form:"-"`
+}
+
+// The layouts offered on the diff page.
+const (
+	diffUnified = "unified"
+	diffSplit   = "split"
+)
+
+// showSnippetDiff compares two snippets, or two revisions of snippets, line
+// by line. Like the search form, the diff form is submitted with GET so that
+// a diff can be linked to.
+func (app *application) showSnippetDiff(w http.ResponseWriter, r *http.Request) {
+	var form diffForm
+	err := app.formDecoder.Decode(&form, r.URL.Query())
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	form.From = strings.TrimSpace(form.From)
+	form.To = strings.TrimSpace(form.To)
+	if form.View == "" {
+		form.View = diffUnified
+	}
+	form.CheckField(validator.PermittedValue(form.View, diffUnified, diffSplit), "view", "This field must equal unified or split")
+
+	data := app.newTemplateData(r)
+	// With nothing to compare yet, just show the form.
+	if form.From == "" && form.To == "" {
+		data.Form = form
+		app.render(w, http.StatusOK, "diff.tmpl", data)
+		return
+	}
+
+	viewerID := app.authenticatedUserID(r)
+	sources := make(map[string]*diffSource, 2)
+	for key, ref := range map[string]string{"from": form.From, "to": form.To} {
+		if ref == "" {
+			form.AddFieldError(key, "This field cannot be blank")
+			continue
+		}
+		source, err := app.findDiffSource(ref, viewerID)
+		if errors.Is(err, models.ErrNoRecord) {
+			form.AddFieldError(key, "There is no snippet or revision with this reference")
+			continue
+		} else if err != nil {
+			app.serverError(w, err)
+			return
+		}
+		sources[key] = source
+	}
+	if !form.Valid() {
+		data.Form = form
+		app.render(w, http.StatusUnprocessableEntity, "diff.tmpl", data)
+		return
+	}
+
+	from, to := sources["from"], sources["to"]
+	data.Form = form
+	data.Diff = compareFiles(from, to, diff.Options{IgnoreWhitespace: form.IgnoreWhitespace})
+	app.render(w, http.StatusOK, "diff.tmpl", data)
 }
 
 // Create a new userSignupForm struct.
@@ -365,6 +1394,66 @@ func (app *application) showAccountView(w http.ResponseWriter, r *http.Request)
 	app.render(w, http.StatusOK, "account.tmpl", data)
 }
 
+// showAccountSnippets lists every snippet owned by the logged-in user.
+func (app *application) showAccountSnippets(w http.ResponseWriter, r *http.Request) {
+	userID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+	snippets, err := app.Snippet.ByUser(userID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	data := app.newTemplateData(r)
+	data.Snippets = snippets
+	app.render(w, http.StatusOK, "snippets.tmpl", data)
+}
+
+// showAccountTrash lists the snippets in the logged-in user's trash.
+func (app *application) showAccountTrash(w http.ResponseWriter, r *http.Request) {
+	userID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+	snippets, err := app.Snippet.Trash(userID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	data := app.newTemplateData(r)
+	data.Snippets = snippets
+	data.TrashDays = app.trashDays
+	app.render(w, http.StatusOK, "trash.tmpl", data)
+}
+
+func (app *application) doAccountTrashRestore(w http.ResponseWriter, r *http.Request) {
+	app.trashAction(w, r, app.Snippet.Restore, "Snippet restored.")
+}
+
+func (app *application) doAccountTrashPurge(w http.ResponseWriter, r *http.Request) {
+	app.trashAction(w, r, app.Snippet.Purge, "Snippet permanently deleted.")
+}
+
+// trashAction applies a model method to the trashed snippet named by the
+// "slug" URL parameter, then redirects back to the trash with a flash message. The
+// model method is responsible for checking that the snippet is in the
+// logged-in user's trash.
+func (app *application) trashAction(w http.ResponseWriter, r *http.Request, action func(slug string, userID int) error, flash string) {
+	params := httprouter.ParamsFromContext(r.Context())
+	slug := params.ByName("slug")
+	if !models.IsSlug(slug) {
+		app.notFound(w)
+		return
+	}
+	userID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+	err := action(slug, userID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+	app.sessionManager.Put(r.Context(), "flash", flash)
+	http.Redirect(w, r, "/account/trash", http.StatusSeeOther)
+}
+
 type accountPasswordUpdateForm struct {
 	CurrentPassword         string `form:"currentPassword"`
 	NewPassword             string `form:"newPassword"`
@@ -1,13 +1,29 @@
 package main
 
 import (
+	"archive/zip"
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
+	"regexp"
 	"runtime/debug"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
 
 	"github.com/go-playground/form/v4"
+	"github.com/julienschmidt/httprouter"
+
+	"github.com/cipto-hd/snippetbox/internal/diff"
+	"github.com/cipto-hd/snippetbox/internal/highlight"
+	"github.com/cipto-hd/snippetbox/internal/models"
 )
 
 // The serverError helper writes an error message and stack trace to the errorLog,
@@ -39,6 +55,12 @@ func (app *application) notFound(w http.ResponseWriter) {
 }
 
 func (app *application) render(w http.ResponseWriter, status int, page string, data *templateData) {
+	app.renderLayout(w, status, page, "base", data)
+}
+
+// renderLayout renders a page inside the named layout template, such as
+// "base" for the full site layout or "embed" for the bare one used in frames.
+func (app *application) renderLayout(w http.ResponseWriter, status int, page string, layout string, data *templateData) {
 	// Retrieve the appropriate template set from the cache based on the page
 	// name (like 'home.tmpl'). If no entry exists in the cache with the
 	// provided name, then create a new error and call the serverError() helper
@@ -54,7 +76,7 @@ func (app *application) render(w http.ResponseWriter, status int, page string, d
 	// Write the template to the buffer, instead of straight to the
 	// http.ResponseWriter. If there's an error, call our serverError() helper
 	// and then return.
-	err := ts.ExecuteTemplate(buf, "base", data)
+	err := ts.ExecuteTemplate(buf, layout, data)
 	if err != nil {
 		app.serverError(w, err)
 		return
@@ -104,3 +126,518 @@ func (app *application) isAuthenticated(r *http.Request) bool {
 
 	return isAuthenticated
 }
+
+// authenticatedUserID returns the ID of the current user if the request is
+// from an authenticated user, otherwise it returns 0. The model treats a
+// viewer ID of 0 as an anonymous viewer.
+func (app *application) authenticatedUserID(r *http.Request) int {
+	if !app.isAuthenticated(r) {
+		return 0
+	}
+	return app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+}
+
+// ownedSnippet fetches the snippet named by the "slug" URL parameter and
+// checks that it belongs to the logged-in user. If it doesn't exist a 404 Not
+// Found response is sent, and if it belongs to somebody else a 403 Forbidden
+// response is sent. In both cases the second return value is false and the
+// calling handler should simply return.
+func (app *application) ownedSnippet(w http.ResponseWriter, r *http.Request) (*models.Snippet, bool) {
+	params := httprouter.ParamsFromContext(r.Context())
+	slug := params.ByName("slug")
+	if !models.IsSlug(slug) {
+		app.notFound(w)
+		return nil, false
+	}
+
+	userID := app.authenticatedUserID(r)
+	snippet, err := app.Snippet.GetBySlug(slug, userID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return nil, false
+	}
+
+	if snippet.UserID != userID {
+		app.clientError(w, http.StatusForbidden)
+		return nil, false
+	}
+
+	return snippet, true
+}
+
+// viewSnippet fetches the snippet named by the "slug" URL parameter for a
+// handler which shows its content, counting the view if it's a
+// burn-after-reading snippet. If the snippet can't be shown it sends the
+// response itself: a redirect for an old numeric URL, 404 Not Found if the
+// snippet doesn't exist or is private to somebody else, or 410 Gone if it has
+// been burned. The 410 response is the burned page, or a plain text message if
+// plain is true. In all of those cases the second return value is false and
+// the calling handler should simply return.
+func (app *application) viewSnippet(w http.ResponseWriter, r *http.Request, plain bool) (*models.Snippet, bool) {
+	// When httprouter is parsing a request, the values of any named parameters
+	// will be stored in the request context. We'll talk about request context
+	// in detail later in the book, but for now it's enough to know that you can
+	// use the ParamsFromContext() function to retrieve a slice containing these
+	// parameter names and values like so:
+	params := httprouter.ParamsFromContext(r.Context())
+	// We can then use the ByName() method to get the value of the "slug" named
+	// parameter from the slice. Links from before snippets had slugs use the
+	// numeric ID instead, so redirect those to the canonical URL.
+	slug := params.ByName("slug")
+	if app.redirectNumericID(w, r, slug) {
+		return nil, false
+	}
+	if !models.IsSlug(slug) {
+		app.notFound(w)
+		return nil, false
+	}
+	// Use the SnippetModel object's View method to retrieve the data for a
+	// specific record based on its slug, counting the view if it's a
+	// burn-after-reading snippet. If no matching record is found, or it's a
+	// private snippet belonging to somebody else, return a 404 Not Found
+	// response. If it has already been burned, say so.
+	//
+	// Link previewers, like the bots which unfurl links pasted into chat,
+	// mustn't use up a view of a burn-after-reading snippet or pass on what
+	// it says, so for them those snippets don't exist.
+	var snippet *models.Snippet
+	var err error
+	if isLinkPreviewer(r) {
+		snippet, err = app.Snippet.GetBySlug(slug, 0)
+		if err == nil && snippet.MaxViews > 0 {
+			err = models.ErrNoRecord
+		}
+	} else {
+		snippet, err = app.Snippet.View(slug, app.authenticatedUserID(r))
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrNoRecord):
+			app.notFound(w)
+		case errors.Is(err, models.ErrBurned) && plain:
+			http.Error(w, "This snippet has been burned", http.StatusGone)
+		case errors.Is(err, models.ErrBurned):
+			app.render(w, http.StatusGone, "burned.tmpl", app.newTemplateData(r))
+		default:
+			app.serverError(w, err)
+		}
+		return nil, false
+	}
+	return snippet, true
+}
+
+// linkPreviewers holds part of the User-Agent of each of the well-known bots
+// which fetch links to show a preview of them.
+var linkPreviewers = []string{
+	"Slackbot", "Twitterbot", "facebookexternalhit", "Discordbot", "LinkedInBot",
+	"WhatsApp", "TelegramBot", "SkypeUriPreview", "Mattermost", "redditbot", "Embedly",
+}
+
+// isLinkPreviewer reports whether a request comes from a link preview bot.
+func isLinkPreviewer(r *http.Request) bool {
+	ua := r.UserAgent()
+	for _, bot := range linkPreviewers {
+		if strings.Contains(ua, bot) {
+			return true
+		}
+	}
+	return false
+}
+
+// forkableSnippet fetches the snippet with the given slug so that the viewer
+// can fork it. Burn-after-reading snippets can only be forked by their owner,
+// since a fork would reveal the content without counting a view; for anybody
+// else, as for snippets which don't exist, ErrNoRecord is returned.
+func (app *application) forkableSnippet(slug string, viewerID int) (*models.Snippet, error) {
+	if !models.IsSlug(slug) {
+		return nil, models.ErrNoRecord
+	}
+	snippet, err := app.Snippet.GetBySlug(slug, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	if snippet.MaxViews > 0 && snippet.UserID != viewerID {
+		return nil, models.ErrNoRecord
+	}
+	return snippet, nil
+}
+
+// A diffSource is one side of a diff: the latest version of a snippet, or one
+// of its revisions.
+type diffSource struct {
+	Snippet  *models.Snippet
+	Revision *models.Revision
+}
+
+// Ref returns the reference which selects this version on the diff page.
+func (s *diffSource) Ref() string {
+	if s.Revision != nil {
+		return fmt.Sprintf("%s@%d", s.Snippet.Slug, s.Revision.Number)
+	}
+	return s.Snippet.Slug
+}
+
+// Title returns the title of this version.
+func (s *diffSource) Title() string {
+	if s.Revision != nil {
+		return s.Revision.Title
+	}
+	return s.Snippet.Title
+}
+
+// Files returns the files of this version.
+func (s *diffSource) Files() []*models.File {
+	if s.Revision != nil {
+		return revisionFiles(s.Snippet, s.Revision)
+	}
+	return snippetFiles(s.Snippet)
+}
+
+// URL returns the path of the page showing this version.
+func (s *diffSource) URL() string {
+	if s.Revision != nil {
+		return fmt.Sprintf("/snippet/view/%s/revision/%d", s.Snippet.Slug, s.Revision.Number)
+	}
+	return "/snippet/view/" + s.Snippet.Slug
+}
+
+// findDiffSource looks up a reference from the diff page, which is either a
+// snippet's slug for its latest version, or a slug and revision number joined
+// by "@", like "aNoldsilentP@2". Snippets are visible on the diff page on the
+// same terms as they can be forked, so ErrNoRecord is returned for
+// burn-after-reading snippets of other users.
+func (app *application) findDiffSource(ref string, viewerID int) (*diffSource, error) {
+	slug, number, hasRevision := strings.Cut(ref, "@")
+	snippet, err := app.forkableSnippet(slug, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	if !hasRevision {
+		return &diffSource{Snippet: snippet}, nil
+	}
+	n, err := strconv.Atoi(number)
+	if err != nil || n < 1 {
+		return nil, models.ErrNoRecord
+	}
+	revision, err := app.Snippet.GetRevision(snippet.ID, n, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	return &diffSource{Snippet: snippet, Revision: revision}, nil
+}
+
+// redirectNumericID handles old-style snippet URLs which use the numeric ID
+// in place of the slug. If param isn't a numeric ID it does nothing and
+// returns false. Otherwise, if numeric IDs are enabled and the snippet is
+// visible to the viewer, it sends a permanent redirect to the same URL with
+// the ID replaced by the slug; if not it sends a 404 Not Found. Either way it
+// returns true and the calling handler should simply return.
+//
+// IDs are sequential, so redirecting every one of them would let anyone find
+// every unlisted snippet by counting. Only public snippets, which are listed
+// anyway, and snippets from before slugs were introduced, whose numeric URLs
+// may already have been shared, are redirected.
+func (app *application) redirectNumericID(w http.ResponseWriter, r *http.Request, param string) bool {
+	id, err := strconv.Atoi(param)
+	if err != nil {
+		return false
+	}
+	if !app.numericIDs || id < 1 {
+		app.notFound(w)
+		return true
+	}
+	snippet, err := app.Snippet.Get(id, app.authenticatedUserID(r))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return true
+	}
+	if snippet.Visibility != models.VisibilityPublic && id > app.numericIDsMax {
+		app.notFound(w)
+		return true
+	}
+	// The ID is always the first purely numeric path segment, because every
+	// segment before it is a fixed part of the route.
+	u := *r.URL
+	u.Path = strings.Replace(u.Path, "/"+param, "/"+snippet.Slug, 1)
+	http.Redirect(w, r, u.RequestURI(), http.StatusMovedPermanently)
+	return true
+}
+
+// readPageQuery builds a models.PageQuery from the "before" and "after" cursors
+// in the request's query string.
+func readPageQuery(r *http.Request, limit int) (models.PageQuery, error) {
+	before, err := models.ParseCursor(r.URL.Query().Get("before"))
+	if err != nil {
+		return models.PageQuery{}, err
+	}
+	after, err := models.ParseCursor(r.URL.Query().Get("after"))
+	if err != nil {
+		return models.PageQuery{}, err
+	}
+	if !before.IsZero() && !after.IsZero() {
+		return models.PageQuery{}, models.ErrInvalidCursor
+	}
+	return models.PageQuery{Before: before, After: after, Limit: limit}, nil
+}
+
+// parseTags splits a comma or space separated list of tags, as typed into the
+// create form, into lowercase tags with duplicates removed.
+func parseTags(s string) []string {
+	var tags []string
+	seen := make(map[string]bool)
+	for _, tag := range strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	}) {
+		tag = strings.ToLower(tag)
+		if !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// rawMaxAge is the longest that shared caches may keep the raw content of a
+// snippet. Snippets can be edited, so it's kept short; revalidating with the
+// ETag afterwards is cheap.
+const rawMaxAge = 5 * time.Minute
+
+// snippetFiles returns the files of a snippet. Snippets saved before they
+// could hold several files have none stored, so they get a single file made
+// from their content, named as it would be downloaded.
+func snippetFiles(s *models.Snippet) []*models.File {
+	if len(s.Files) > 0 {
+		return s.Files
+	}
+	return []*models.File{{
+		SnippetID: s.ID,
+		Name:      snippetFilename(s),
+		Language:  s.Language,
+		Content:   s.Content,
+	}}
+}
+
+// revisionFiles returns the files of a revision of s, like snippetFiles().
+// Revisions saved before every file was kept only have the content of the
+// main file, which is shown under the current main file's name.
+func revisionFiles(s *models.Snippet, rev *models.Revision) []*models.File {
+	if len(rev.Files) > 0 {
+		return rev.Files
+	}
+	mainFile := *snippetFiles(s)[0]
+	mainFile.Content = rev.Content
+	if rev.Language != "" {
+		mainFile.Language = rev.Language
+	}
+	return []*models.File{&mainFile}
+}
+
+// compareFiles compares the files of two versions. The main files are always
+// compared with each other, so that two different snippets can be compared,
+// and the other files are matched up by name. A file in only one of the
+// versions is compared with an empty one.
+func compareFiles(from, to *diffSource, opts diff.Options) *snippetDiff {
+	d := &snippetDiff{From: from, To: to}
+	add := func(oldFile, newFile *models.File) {
+		var oldContent, newContent string
+		if oldFile != nil {
+			oldContent = oldFile.Content
+		}
+		if newFile != nil {
+			newContent = newFile.Content
+		}
+		result := diff.Compare(oldContent, newContent, opts)
+		d.Files = append(d.Files, &fileDiff{Old: oldFile, New: newFile, Result: result})
+		d.Added += result.Added
+		d.Deleted += result.Deleted
+	}
+
+	oldFiles, newFiles := from.Files(), to.Files()
+	add(oldFiles[0], newFiles[0])
+	matched := make([]bool, len(newFiles))
+	for _, f := range oldFiles[1:] {
+		i := slices.IndexFunc(newFiles[1:], func(g *models.File) bool { return g.Name == f.Name })
+		if i < 0 {
+			add(f, nil)
+			continue
+		}
+		matched[i+1] = true
+		add(f, newFiles[i+1])
+	}
+	for i, f := range newFiles[1:] {
+		if !matched[i+1] {
+			add(nil, f)
+		}
+	}
+	return d
+}
+
+// defaultFilename returns the name given to the file at index i of a snippet
+// if none is chosen, like "file1.go".
+func defaultFilename(i int, language string) string {
+	ext := highlight.Text.Extension
+	if lang := highlight.Lookup(language); lang != nil {
+		ext = lang.Extension
+	}
+	return fmt.Sprintf("file%d.%s", i+1, ext)
+}
+
+// writeSnippetZip writes the files of a snippet to w as a zip archive.
+func writeSnippetZip(w io.Writer, s *models.Snippet) error {
+	zw := zip.NewWriter(w)
+	for _, f := range snippetFiles(s) {
+		fw, err := zw.CreateHeader(&zip.FileHeader{
+			Name:     f.Name,
+			Method:   zip.Deflate,
+			Modified: s.Updated,
+		})
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(fw, f.Content)
+		if err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// serveSnippetContent writes the content of a snippet as plain text, with
+// caching headers suited to who is allowed to see it. Conditional and range
+// requests are handled by http.ServeContent(), using an ETag derived from the
+// content, except for burn-after-reading snippets.
+func serveSnippetContent(w http.ResponseWriter, r *http.Request, s *models.Snippet) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", snippetCacheControl(s, time.Now()))
+	// Fetching a burn-after-reading snippet has already used up a view, so
+	// the whole content is always sent. A 304 Not Modified or a partial
+	// response would use up the view without delivering it.
+	if s.MaxViews > 0 {
+		w.Header().Set("Content-Length", strconv.Itoa(len(s.Content)))
+		io.WriteString(w, s.Content)
+		return
+	}
+	sum := sha256.Sum256([]byte(s.Content))
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+	http.ServeContent(w, r, "", s.Updated, strings.NewReader(s.Content))
+}
+
+// snippetCacheControl returns the Cache-Control header for the raw content of
+// a snippet. Public and unlisted snippets can be cached by anyone, but never
+// beyond their expiry; private snippets only by the owner's browser, and
+// burn-after-reading snippets not at all, because every fetch counts as a
+// view.
+func snippetCacheControl(s *models.Snippet, now time.Time) string {
+	switch {
+	case s.MaxViews > 0:
+		return "no-store"
+	case s.Visibility == models.VisibilityPrivate:
+		return "private, no-cache"
+	}
+	maxAge := rawMaxAge
+	if !s.Expires.IsZero() && s.Expires.Sub(now) < maxAge {
+		maxAge = max(s.Expires.Sub(now), 0)
+	}
+	return fmt.Sprintf("public, max-age=%d", int(maxAge.Seconds()))
+}
+
+// maxFilenameLength is the longest that the title part of a download's
+// filename can be.
+const maxFilenameLength = 60
+
+// originHostRX matches the host and optional port of an origin. The host may
+// start with a "*." wildcard.
+var originHostRX = regexp.MustCompile(`^(?:\*\.)?[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)*(?::[0-9]+)?$`)
+
+// parseOrigins parses a space-separated list of origins, like
+// "https://wiki.example.com https://*.example.org", for the frame-ancestors
+// directive. Each must be an http or https URL with a host and nothing after
+// it.
+func parseOrigins(list string) ([]string, error) {
+	var origins []string
+	for _, field := range strings.Fields(list) {
+		u, err := url.Parse(field)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || !originHostRX.MatchString(u.Host) ||
+			u.User != nil || (u.Path != "" && u.Path != "/") || u.RawQuery != "" || u.Fragment != "" {
+			return nil, fmt.Errorf("invalid origin %q", field)
+		}
+		origins = append(origins, u.Scheme+"://"+u.Host)
+	}
+	return origins, nil
+}
+
+// formOverhead is the room allowed in a request body for everything but the
+// content of a snippet's files.
+const formOverhead = 64 * 1024
+
+// maxRequestSize returns the size in bytes of the largest request body which
+// is accepted. URL-encoding a form can take three bytes for every byte of a
+// file's content, so that's allowed for on top of maxSnippetSize.
+func (app *application) maxRequestSize() int64 {
+	return 3*int64(app.maxSnippetSize) + formOverhead
+}
+
+// formatSize formats a number of bytes for people to read, like "64 KB" or
+// "1.5 MB".
+func formatSize(n int) string {
+	units := []string{"bytes", "KB", "MB", "GB"}
+	size := float64(n)
+	i := 0
+	for size >= 1024 && i < len(units)-1 {
+		size /= 1024
+		i++
+	}
+	return strings.TrimSuffix(strconv.FormatFloat(size, 'f', 1, 64), ".0") + " " + units[i]
+}
+
+// downloadFilename returns the filename for downloading a snippet: its main
+// file's name, unless the file was left with a default name like "file1.go",
+// in which case a name is made from the title by snippetFilename().
+func downloadFilename(s *models.Snippet) string {
+	mainFile := snippetFiles(s)[0]
+	if mainFile.Name == defaultFilename(0, mainFile.Language) {
+		return snippetFilename(s)
+	}
+	return mainFile.Name
+}
+
+// snippetFilename returns the filename for downloading a snippet, made from
+// its title and the usual extension for its language, for example
+// "an-old-silent-pond.txt". Only ASCII letters and digits are kept from the
+// title, with everything else collapsed into single dashes, so the name is
+// safe on every platform.
+func snippetFilename(s *models.Snippet) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(s.Title) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+		} else {
+			dash = true
+		}
+		if b.Len() >= maxFilenameLength {
+			break
+		}
+	}
+	name := strings.TrimRight(b.String()[:min(b.Len(), maxFilenameLength)], "-")
+	if name == "" {
+		name = "snippet"
+	}
+	ext := highlight.Text.Extension
+	if lang := highlight.Lookup(s.Language); lang != nil {
+		ext = lang.Extension
+	}
+	return name + "." + ext
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cipto-hd/snippetbox/internal/assert"
+	"github.com/cipto-hd/snippetbox/internal/diff"
+	"github.com/cipto-hd/snippetbox/internal/models"
+)
+
+func TestSnippetFilename(t *testing.T) {
+	tests := []struct {
+		name     string
+		title    string
+		language string
+		want     string
+	}{
+		{
+			name:     "Plain text",
+			title:    "An old silent pond",
+			language: "text",
+			want:     "an-old-silent-pond.txt",
+		},
+		{
+			name:     "Language extension",
+			title:    "Hello, World!",
+			language: "go",
+			want:     "hello-world.go",
+		},
+		{
+			name:     "Non-ASCII title",
+			title:    "Ünïcode ☃ script",
+			language: "shell",
+			want:     "n-code-script.sh",
+		},
+		{
+			name:     "Nothing usable in the title",
+			title:    "☃☃☃",
+			language: "python",
+			want:     "snippet.py",
+		},
+		{
+			name:     "Long title",
+			title:    strings.Repeat("a", 50) + " " + strings.Repeat("b", 50),
+			language: "text",
+			want:     strings.Repeat("a", 50) + "-" + strings.Repeat("b", 9) + ".txt",
+		},
+		{
+			name:     "Unknown language",
+			title:    "Notes",
+			language: "cobol",
+			want:     "notes.txt",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &models.Snippet{Title: tt.title, Language: tt.language}
+			assert.Equal(t, snippetFilename(s), tt.want)
+		})
+	}
+}
+
+func TestDownloadFilename(t *testing.T) {
+	tests := []struct {
+		name  string
+		files []*models.File
+		want  string
+	}{
+		{
+			name: "Named file",
+			files: []*models.File{
+				{Name: "pond.txt", Language: "text"},
+				{Name: "frog.go", Language: "go"},
+			},
+			want: "pond.txt",
+		},
+		{
+			name: "Unnamed file",
+			files: []*models.File{
+				{Name: "file1.go", Language: "go"},
+				{Name: "file2.txt", Language: "text"},
+			},
+			want: "an-old-silent-pond.go",
+		},
+		{
+			name: "No files",
+			want: "an-old-silent-pond.go",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &models.Snippet{Title: "An old silent pond", Language: "go", Files: tt.files}
+			assert.Equal(t, downloadFilename(s), tt.want)
+		})
+	}
+}
+
+func TestSnippetCacheControl(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name    string
+		snippet *models.Snippet
+		want    string
+	}{
+		{
+			name:    "Public",
+			snippet: &models.Snippet{Visibility: models.VisibilityPublic, Expires: now.Add(time.Hour)},
+			want:    "public, max-age=300",
+		},
+		{
+			name:    "Never expires",
+			snippet: &models.Snippet{Visibility: models.VisibilityUnlisted},
+			want:    "public, max-age=300",
+		},
+		{
+			name:    "Expires soon",
+			snippet: &models.Snippet{Visibility: models.VisibilityPublic, Expires: now.Add(time.Minute)},
+			want:    "public, max-age=60",
+		},
+		{
+			name:    "Private",
+			snippet: &models.Snippet{Visibility: models.VisibilityPrivate},
+			want:    "private, no-cache",
+		},
+		{
+			name:    "Burn after reading",
+			snippet: &models.Snippet{Visibility: models.VisibilityUnlisted, MaxViews: 1},
+			want:    "no-store",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, snippetCacheControl(tt.snippet, now), tt.want)
+		})
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	tests := []struct {
+		n    int
+		want string
+	}{
+		{n: 100, want: "100 bytes"},
+		{n: 64 * 1024, want: "64 KB"},
+		{n: 1536 * 1024, want: "1.5 MB"},
+		{n: 2 * 1024 * 1024 * 1024, want: "2 GB"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			assert.Equal(t, formatSize(tt.n), tt.want)
+		})
+	}
+}
+
+func TestParseOrigins(t *testing.T) {
+	tests := []struct {
+		name    string
+		list    string
+		want    []string
+		wantErr bool
+	}{
+		{name: "Empty", list: ""},
+		{
+			name: "Several",
+			list: " https://wiki.example.com  http://localhost:8080/ https://*.example.org",
+			want: []string{"https://wiki.example.com", "http://localhost:8080", "https://*.example.org"},
+		},
+		{name: "No scheme", list: "wiki.example.com", wantErr: true},
+		{name: "Other scheme", list: "ftp://wiki.example.com", wantErr: true},
+		{name: "Path", list: "https://wiki.example.com/page", wantErr: true},
+		{name: "Directive injection", list: "https://a.example.com;script-src", wantErr: true},
+		{name: "Keyword", list: "'none'", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			origins, err := parseOrigins(tt.list)
+			assert.Equal(t, err != nil, tt.wantErr)
+			assert.Equal(t, strings.Join(origins, " "), strings.Join(tt.want, " "))
+		})
+	}
+}
+
+func TestCompareFiles(t *testing.T) {
+	from := &diffSource{
+		Snippet: &models.Snippet{Slug: "aNoldsilentP"},
+		Revision: &models.Revision{Number: 1, Files: []*models.File{
+			{Name: "main.go", Content: "package main"},
+			{Name: "README", Content: "Run it"},
+			{Name: "old.txt", Content: "Gone"},
+		}},
+	}
+	to := &diffSource{
+		Snippet: &models.Snippet{Slug: "aNoldsilentP", Files: []*models.File{
+			{Name: "cmd.go", Content: "package main"},
+			{Name: "new.txt", Content: "Added\nlines"},
+			{Name: "README", Content: "Run it with go run"},
+		}},
+	}
+	d := compareFiles(from, to, diff.Options{})
+
+	var names []string
+	for _, f := range d.Files {
+		names = append(names, f.Name())
+	}
+	// The main files are compared even though they were renamed, the other
+	// files by name, and files in only one version come last.
+	assert.Equal(t, strings.Join(names, ", "), "main.go → cmd.go, README, old.txt, new.txt")
+	assert.Equal(t, d.Files[0].Result.Identical(), true)
+	assert.Equal(t, d.Files[2].New == nil, true)
+	assert.Equal(t, d.Files[3].Old == nil, true)
+	assert.Equal(t, d.Added, 3)
+	assert.Equal(t, d.Deleted, 2)
+	assert.Equal(t, d.Identical(), false)
+}
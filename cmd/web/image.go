@@ -0,0 +1,56 @@
+package main
+
+import (
+	"strconv"
+
+	"github.com/cipto-hd/snippetbox/internal/codeimage"
+	"github.com/cipto-hd/snippetbox/internal/models"
+	"github.com/cipto-hd/snippetbox/ui"
+)
+
+// newCodeImageRenderer returns a renderer for images of snippets, using the
+// Go Mono fonts embedded in ui.Files, which caches up to cacheSize bytes of
+// images.
+func newCodeImageRenderer(cacheSize int) (*codeimage.Renderer, error) {
+	regular, err := ui.Files.ReadFile("fonts/Go-Mono.ttf")
+	if err != nil {
+		return nil, err
+	}
+	bold, err := ui.Files.ReadFile("fonts/Go-Mono-Bold.ttf")
+	if err != nil {
+		return nil, err
+	}
+	return codeimage.New(regular, bold, cacheSize)
+}
+
+// codeImageOptions returns the options for an image of file number n of a
+// snippet, counting from 1. The title bar shows the snippet's title, with the
+// file's name when there are several files. It returns false if there's no
+// such file.
+func codeImageOptions(s *models.Snippet, n int, theme *codeimage.Theme, lineNumbers bool) (codeimage.Options, bool) {
+	files := snippetFiles(s)
+	if n < 1 || n > len(files) {
+		return codeimage.Options{}, false
+	}
+	f := files[n-1]
+	title := s.Title
+	if len(files) > 1 {
+		title += " — " + f.Name
+	}
+	return codeimage.Options{
+		Title:       title,
+		Language:    f.Language,
+		Content:     f.Content,
+		Theme:       theme,
+		LineNumbers: lineNumbers,
+	}, true
+}
+
+// parseFileNumber reads the optional "file" query parameter of an image URL,
+// which defaults to the main file.
+func parseFileNumber(v string) (int, error) {
+	if v == "" {
+		return 1, nil
+	}
+	return strconv.Atoi(v)
+}
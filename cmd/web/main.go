@@ -1,13 +1,20 @@
 package main
 
 import (
+	"context"
 	"crypto/tls"
 	"database/sql"
+	"errors"
+	"expvar"
 	"flag"
 	"html/template"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/alexedwards/scs/mysqlstore"
@@ -15,7 +22,9 @@ import (
 	"github.com/go-playground/form/v4"
 	_ "github.com/go-sql-driver/mysql" // New import
 
+	"github.com/cipto-hd/snippetbox/internal/codeimage"
 	"github.com/cipto-hd/snippetbox/internal/models"
+	"github.com/cipto-hd/snippetbox/internal/secrets"
 )
 
 type application struct {
@@ -24,9 +33,21 @@ type application struct {
 	errorLog       *log.Logger
 	Snippet        models.SnippetModelInterface
 	User           models.UserModelInterface
+	Session        models.SessionModelInterface
 	templateCache  map[string]*template.Template
 	formDecoder    *form.Decoder
 	sessionManager *scs.SessionManager
+	trashDays      int
+	numericIDs     bool
+	numericIDsMax  int
+	maxFileSize    int
+	maxSnippetSize int
+	baseURL        string
+	embedOrigins   []string
+	robots         string
+	codeImages     *codeimage.Renderer
+	secretRules    []secrets.Rule
+	blockSecrets   bool
 }
 
 func main() {
@@ -35,11 +56,60 @@ func main() {
 	dsn := flag.String("dsn", "web:pass@/snippetbox?parseTime=true", "MySQL data source name")
 	// Create a new debug flag with the default value of false.
 	debug := flag.Bool("debug", false, "Enable debug mode")
+	// Define a flag for how many days deleted snippets stay restorable in the
+	// trash before they are purged.
+	trashDays := flag.Int("trash-days", 30, "Days a deleted snippet stays in the trash")
+	// Define a flag controlling whether old numeric snippet URLs still work.
+	// When enabled they redirect to the snippet's slug-based URL, when
+	// disabled they return 404 Not Found. Only public snippets are
+	// redirected, along with the snippets created before slugs, whose numeric
+	// URLs may have been shared.
+	numericIDs := flag.Bool("numeric-ids", true, "Redirect numeric snippet URLs to their slug-based equivalents")
+	// Define flags for the background reaper, which deletes expired snippets,
+	// old trash and expired sessions, and for the address of the optional
+	// metrics server which reports what it has done.
+	reapInterval := flag.Duration("reap-interval", 10*time.Minute, "How often to delete expired data")
+	reapBatch := flag.Int("reap-batch", 1000, "Maximum rows deleted by each reaper statement")
+	// Define flags for the largest snippets which can be saved, in bytes.
+	// Request bodies are limited to match, so anything much bigger is
+	// rejected before it's read.
+	maxFileSize := flag.Int("max-file-size", 256*1024, "Maximum size of each file in a snippet, in bytes")
+	maxSnippetSize := flag.Int("max-snippet-size", 1024*1024, "Maximum total size of the files in a snippet, in bytes")
+	// Define a flag for the URL the site is served from, which is used
+	// wherever a link has to be absolute, and one for the origins of the
+	// sites allowed to embed snippets in a frame.
+	baseURL := flag.String("base-url", "https://localhost:4000", "Public URL of the site, used in absolute links")
+	embedOrigins := flag.String("embed-origins", "", "Space-separated origins allowed to embed snippets, e.g. https://wiki.example.com")
+	// Define a flag for a robots.txt file to serve instead of the default
+	// one, which lets crawlers see everything but the account pages.
+	robotsFile := flag.String("robots-file", "", "Path of a robots.txt file to serve instead of the default")
+	// Define a flag for how much memory to give over to caching the images
+	// of snippets.
+	imageCacheSize := flag.Int("image-cache-size", 32*1024*1024, "Maximum size of the snippet image cache, in bytes")
+	// Define flags for checking new snippets for secrets: a file of rules to
+	// add to the built-in ones, and whether snippets with secrets are refused
+	// outright rather than published once the user confirms.
+	secretRulesFile := flag.String("secret-rules", "", "Path of a file of extra secret rules, each a name and a regular expression separated by a tab")
+	blockSecrets := flag.Bool("block-secrets", false, "Refuse to publish snippets which appear to contain secrets")
+	metricsAddr := flag.String("metrics-addr", "", "Address for the expvar metrics server, e.g. localhost:4001 (disabled if empty)")
 	flag.Parse()
 
 	infoLog := log.New(os.Stdout, "INFO\t", log.Ldate|log.Ltime)
 	errorLog := log.New(os.Stderr, "ERROR\t", log.Ldate|log.Ltime|log.Lshortfile)
 
+	origins, err := parseOrigins(*embedOrigins)
+	if err != nil {
+		errorLog.Fatal(err)
+	}
+	robots, err := loadRobots(*robotsFile, strings.TrimSuffix(*baseURL, "/"))
+	if err != nil {
+		errorLog.Fatal(err)
+	}
+	secretRules, err := loadSecretRules(*secretRulesFile)
+	if err != nil {
+		errorLog.Fatal(err)
+	}
+
 	// To keep the main() function tidy I've put the code for creating a connection
 	// pool into the separate openDB() function below. We pass openDB() the DSN
 	// from the command-line flag.
@@ -57,14 +127,21 @@ func main() {
 		errorLog.Fatal(err)
 	}
 
+	// And the renderer for images of snippets, with its fonts.
+	codeImages, err := newCodeImageRenderer(*imageCacheSize)
+	if err != nil {
+		errorLog.Fatal(err)
+	}
+
 	// Initialize a decoder instance...
 	formDecoder := form.NewDecoder()
 	// Use the scs.New() function to initialize a new session manager. Then we
 	// configure it to use our MySQL database as the session store, and set a
 	// lifetime of 12 hours (so that sessions automatically expire 12 hours
-	// after first being created).
+	// after first being created). Expired sessions are deleted by our reaper,
+	// so the store's own cleanup goroutine is disabled.
 	sessionManager := scs.New()
-	sessionManager.Store = mysqlstore.New(db)
+	sessionManager.Store = mysqlstore.NewWithCleanupInterval(db, 0)
 	sessionManager.Lifetime = 12 * time.Hour
 
 	app := &application{
@@ -73,10 +150,56 @@ func main() {
 		errorLog:       errorLog,
 		Snippet:        &models.SnippetModel{DB: db},
 		User:           &models.UserModel{DB: db},
+		Session:        &models.SessionModel{DB: db},
 		templateCache:  templateCache,
 		formDecoder:    formDecoder,
 		sessionManager: sessionManager,
+		trashDays:      *trashDays,
+		numericIDs:     *numericIDs,
+		maxFileSize:    *maxFileSize,
+		maxSnippetSize: *maxSnippetSize,
+		baseURL:        strings.TrimSuffix(*baseURL, "/"),
+		embedOrigins:   origins,
+		robots:         robots,
+		codeImages:     codeImages,
+		secretRules:    secretRules,
+		blockSecrets:   *blockSecrets,
+	}
+
+	// Look up which snippets were created before slugs, so that their
+	// numeric URLs keep working.
+	if app.numericIDs {
+		app.numericIDsMax, err = app.Snippet.MaxNumericID()
+		if err != nil {
+			errorLog.Fatal(err)
+		}
+	}
+
+	// Cancel ctx when the process is asked to stop, so that the server and the
+	// background goroutines can shut down cleanly.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Start the reaper in a background goroutine, and keep track of it so that
+	// we can wait for it to finish what it's doing before exiting.
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		app.reap(ctx, app.reapTasks(), *reapInterval, *reapBatch)
+	}()
+
+	// Serve the expvar metrics, including the reaper's counts, on a separate
+	// address which can be kept private. They aren't added to the
+	// application's routes because they include the command line, and so the
+	// DSN.
+	if *metricsAddr != "" {
+		go func() {
+			infoLog.Printf("Serving metrics on %s", *metricsAddr)
+			errorLog.Print(http.ListenAndServe(*metricsAddr, expvar.Handler()))
+		}()
 	}
+
 	// Initialize a tls.Config struct to hold the non-default TLS settings we
 	// want the server to use. In this case the only thing that we're changing
 	// is the curve preferences value, so that only elliptic curves with
@@ -99,10 +222,31 @@ func main() {
 	infoLog.Printf("Start listening on %s", *addr)
 	// Use the ListenAndServeTLS() method to start the HTTPS server. We
 	// pass in the paths to the TLS certificate and corresponding private key as
-	// the two parameters.
-	err = srv.ListenAndServeTLS("./tls/cert.pem", "./tls/key.pem")
-	// err = srv.ListenAndServe()
-	errorLog.Fatal(err)
+	// the two parameters. It runs in its own goroutine so that main() can
+	// wait for a shutdown signal at the same time.
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- srv.ListenAndServeTLS("./tls/cert.pem", "./tls/key.pem")
+		// serverErr <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err = <-serverErr:
+		errorLog.Fatal(err)
+	case <-ctx.Done():
+	}
+
+	// Give in-flight requests a few seconds to finish, then wait for the
+	// reaper to finish its current batch.
+	infoLog.Print("Shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	err = srv.Shutdown(shutdownCtx)
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		errorLog.Print(err)
+	}
+	wg.Wait()
+	infoLog.Print("Stopped")
 }
 
 // The openDB() function wraps sql.Open() and returns a sql.DB connection pool
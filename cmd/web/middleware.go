@@ -2,18 +2,21 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/justinas/nosurf"
 )
 
+// contentSecurityPolicy is the Content-Security-Policy sent with every
+// response.
+const contentSecurityPolicy = "default-src 'self'; style-src 'self' fonts.googleapis.com; font-src fonts.gstatic.com"
+
 func secureHeaders(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Note: This is split across multiple lines for readability. You don't
-		// need to do this in your own code.
-		w.Header().Set("Content-Security-Policy",
-			"default-src 'self'; style-src 'self' fonts.googleapis.com; font-src fonts.gstatic.com")
+		w.Header().Set("Content-Security-Policy", contentSecurityPolicy)
 		w.Header().Set("Referrer-Policy", "origin-when-cross-origin")
 		w.Header().Set("X-Content-Type-Options", "nosniff")
 		w.Header().Set("X-Frame-Options", "deny")
@@ -37,6 +40,12 @@ func (app *application) recoverPanic(next http.Handler) http.Handler {
 			// Use the builtin recover function to check if there has been a
 			// panic or not. If there has...
 			if err := recover(); err != nil {
+				// http.ErrAbortHandler is how a handler cuts a response
+				// short on purpose, so it's passed on for the server to
+				// close the connection without logging it.
+				if err == http.ErrAbortHandler {
+					panic(err)
+				}
 				// Set a "Connection: close" header on the response.
 				w.Header().Set("Connection", "close")
 				// Call the app.serverError helper method to return a 500
@@ -69,6 +78,44 @@ func (app *application) requireAuthentication(next http.Handler) http.Handler {
 	})
 }
 
+// allowFraming relaxes the headers set by secureHeaders so that the page can
+// be shown in a frame by this site and by the origins in app.embedOrigins.
+// X-Frame-Options can't name other sites, so it's dropped in favour of the
+// Content-Security-Policy frame-ancestors directive.
+func (app *application) allowFraming(next http.Handler) http.Handler {
+	ancestors := append([]string{"'self'"}, app.embedOrigins...)
+	csp := contentSecurityPolicy + "; frame-ancestors " + strings.Join(ancestors, " ")
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Security-Policy", csp)
+		w.Header().Del("X-Frame-Options")
+		next.ServeHTTP(w, r)
+	})
+}
+
+// limitRequestSize stops requests with bodies bigger than maxRequestSize().
+// POST forms are read here, so that a form which is too big gets a 413
+// Request Entity Too Large response rather than failing the CSRF check, which
+// has to read the form first.
+func (app *application) limitRequestSize(next http.Handler) http.Handler {
+	limit := app.maxRequestSize()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
+		if r.Method == http.MethodPost {
+			err := r.ParseForm()
+			if err != nil {
+				var maxBytesError *http.MaxBytesError
+				if errors.As(err, &maxBytesError) {
+					app.clientError(w, http.StatusRequestEntityTooLarge)
+				} else {
+					app.clientError(w, http.StatusBadRequest)
+				}
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
 // Create a NoSurf middleware function which uses a customized CSRF cookie with
 // the Secure, Path and HttpOnly attributes set.
 func noSurf(next http.Handler) http.Handler {
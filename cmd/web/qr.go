@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+
+	"rsc.io/qr"
+)
+
+// qrScale is the size in pixels of each module, the small square which is
+// the unit of a QR code, in the PNG version of a code. It's big enough to be
+// read from a screen across a room.
+const qrScale = 8
+
+// qrQuietZone is the width, in modules, of the blank border which the QR code
+// standard asks for around a code. The PNG encoder adds one of the same width.
+const qrQuietZone = 4
+
+// qrCode encodes url as a QR code. Medium error correction copes with a
+// scuffed screen or a printout without making the code much denser.
+func qrCode(url string) (*qr.Code, error) {
+	code, err := qr.Encode(url, qr.M)
+	if err != nil {
+		return nil, err
+	}
+	code.Scale = qrScale
+	return code, nil
+}
+
+// qrSVG draws code as an SVG image, one unit to a module, so that it can be
+// scaled to any size. Each run of dark modules in a row is drawn as a single
+// rectangle, which keeps the file small.
+func qrSVG(code *qr.Code) []byte {
+	size := code.Size + 2*qrQuietZone
+	var b bytes.Buffer
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d" shape-rendering="crispEdges">`, size, size)
+	fmt.Fprintf(&b, `<rect width="%d" height="%d" fill="#fff"/><path fill="#000" d="`, size, size)
+	for y := 0; y < code.Size; y++ {
+		for x := 0; x < code.Size; x++ {
+			if !code.Black(x, y) {
+				continue
+			}
+			start := x
+			for x+1 < code.Size && code.Black(x+1, y) {
+				x++
+			}
+			fmt.Fprintf(&b, "M%d %dh%dv1h-%dz", start+qrQuietZone, y+qrQuietZone, x-start+1, x-start+1)
+		}
+	}
+	b.WriteString(`"/></svg>`)
+	return b.Bytes()
+}
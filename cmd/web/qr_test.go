@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"testing"
+
+	"github.com/cipto-hd/snippetbox/internal/assert"
+)
+
+// qrRunRX matches one run of dark modules in the path drawn by qrSVG().
+var qrRunRX = regexp.MustCompile(`M(\d+) (\d+)h(\d+)v1h-(\d+)z`)
+
+func TestQRSVG(t *testing.T) {
+	code, err := qrCode("https://snippetbox.example/snippet/view/aNoldsilentP")
+	assert.NilError(t, err)
+	svg := string(qrSVG(code))
+	size := code.Size + 2*qrQuietZone
+	assert.StringContains(t, svg, fmt.Sprintf(`viewBox="0 0 %d %d"`, size, size))
+
+	// Redraw the code from the runs in the path, and check that every
+	// module comes out the same.
+	dark := make(map[[2]int]bool)
+	for _, m := range qrRunRX.FindAllStringSubmatch(svg, -1) {
+		x, _ := strconv.Atoi(m[1])
+		y, _ := strconv.Atoi(m[2])
+		n, _ := strconv.Atoi(m[3])
+		for i := 0; i < n; i++ {
+			dark[[2]int{x + i - qrQuietZone, y - qrQuietZone}] = true
+		}
+	}
+	for y := 0; y < code.Size; y++ {
+		for x := 0; x < code.Size; x++ {
+			if dark[[2]int{x, y}] != code.Black(x, y) {
+				t.Fatalf("module (%d, %d): got dark %t; want %t", x, y, dark[[2]int{x, y}], code.Black(x, y))
+			}
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"expvar"
+	"time"
+)
+
+// reaperStats holds the counters published by the reaper, so that operators
+// can check that it's working. They're served as JSON by expvar, under the
+// "reaper" key, when the -metrics-addr flag is set.
+var reaperStats = expvar.NewMap("reaper")
+
+// A reapTask deletes up to limit rows of one kind of stale data, and returns
+// how many it deleted.
+type reapTask struct {
+	name string
+	run  func(limit int) (int64, error)
+}
+
+// reapTasks lists everything the reaper cleans up: snippets which have
+// expired, snippets which have been in the trash for longer than trashDays,
+// large snippet content which is no longer used, and expired sessions.
+func (app *application) reapTasks() []reapTask {
+	return []reapTask{
+		{"expired_snippets", app.Snippet.DeleteExpired},
+		{"trashed_snippets", func(limit int) (int64, error) {
+			return app.Snippet.PurgeTrash(app.trashDays, limit)
+		}},
+		{"unused_contents", app.Snippet.PurgeContents},
+		{"expired_sessions", app.Session.DeleteExpired},
+	}
+}
+
+// reap runs every reap task once per interval until ctx is cancelled. Each
+// task deletes rows in batches of batchSize, so that no single statement holds
+// locks for long. It's intended to be run in its own goroutine; when ctx is
+// cancelled it finishes the batch in progress and returns.
+func (app *application) reap(ctx context.Context, tasks []reapTask, interval time.Duration, batchSize int) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		for _, task := range tasks {
+			n, err := reapAll(ctx, task, batchSize)
+			reaperStats.Add(task.name, n)
+			if err != nil {
+				app.errorLog.Printf("reaper: %s: %s", task.name, err)
+			}
+			if n > 0 {
+				app.infoLog.Printf("Reaper deleted %d %s", n, task.name)
+			}
+		}
+		reaperStats.Add("runs", 1)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// reapAll runs a reap task in batches of batchSize until there's nothing left
+// for it to delete, an error occurs or ctx is cancelled. It returns the total
+// number of rows deleted.
+func reapAll(ctx context.Context, task reapTask, batchSize int) (int64, error) {
+	var total int64
+	for ctx.Err() == nil {
+		n, err := task.run(batchSize)
+		total += n
+		if err != nil || n < int64(batchSize) {
+			return total, err
+		}
+	}
+	return total, nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"testing"
+	"time"
+
+	"github.com/cipto-hd/snippetbox/internal/assert"
+)
+
+// batchTask returns a reap task which deletes the given number of rows from
+// each successive batch, and a pointer to the number of times it was run.
+func batchTask(name string, batches ...int64) (reapTask, *int) {
+	calls := 0
+	return reapTask{name: name, run: func(limit int) (int64, error) {
+		calls++
+		if calls > len(batches) {
+			return 0, nil
+		}
+		return batches[calls-1], nil
+	}}, &calls
+}
+
+func TestReapAll(t *testing.T) {
+	t.Run("Batches", func(t *testing.T) {
+		task, calls := batchTask("test", 10, 10, 3)
+		n, err := reapAll(context.Background(), task, 10)
+		assert.NilError(t, err)
+		assert.Equal(t, n, int64(23))
+		assert.Equal(t, *calls, 3)
+	})
+	t.Run("Exact multiple of the batch size", func(t *testing.T) {
+		task, calls := batchTask("test", 10, 10)
+		n, err := reapAll(context.Background(), task, 10)
+		assert.NilError(t, err)
+		assert.Equal(t, n, int64(20))
+		assert.Equal(t, *calls, 3)
+	})
+	t.Run("Error", func(t *testing.T) {
+		errDB := errors.New("database is down")
+		calls := 0
+		task := reapTask{name: "test", run: func(limit int) (int64, error) {
+			calls++
+			if calls == 2 {
+				return 0, errDB
+			}
+			return int64(limit), nil
+		}}
+		n, err := reapAll(context.Background(), task, 10)
+		assert.Equal(t, err, errDB)
+		assert.Equal(t, n, int64(10))
+	})
+	t.Run("Cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		task, calls := batchTask("test", 10)
+		n, err := reapAll(ctx, task, 10)
+		assert.NilError(t, err)
+		assert.Equal(t, n, int64(0))
+		assert.Equal(t, *calls, 0)
+	})
+}
+
+func TestReap(t *testing.T) {
+	app := newTestApplication(t)
+	task, calls := batchTask("test_reap", 4)
+
+	// With a context that's already cancelled, reap() returns without running
+	// any batches.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	done := make(chan struct{})
+	go func() {
+		app.reap(ctx, []reapTask{task}, time.Hour, 10)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("reap() didn't stop when its context was cancelled")
+	}
+	assert.Equal(t, *calls, 0)
+
+	// Cancelling while a pass is running lets the batch in progress finish.
+	ctx, cancel = context.WithCancel(context.Background())
+	task.run = func(limit int) (int64, error) {
+		*calls++
+		cancel()
+		return 4, nil
+	}
+	app.reap(ctx, []reapTask{task}, time.Hour, 10)
+	assert.Equal(t, *calls, 1)
+	assert.Equal(t, reaperStats.Get("test_reap").(*expvar.Int).Value(), int64(4))
+}
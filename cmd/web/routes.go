@@ -39,7 +39,8 @@ func (app application) routes() http.Handler {
 	router.HandlerFunc(http.MethodGet, "/ping", ping)
 
 	// Unprotected application routes using the "dynamic" middleware chain.
-	dynamic := alice.New(app.sessionManager.LoadAndSave, noSurf, app.authenticate)
+	// Request bodies are limited before noSurf reads them.
+	dynamic := alice.New(app.limitRequestSize, app.sessionManager.LoadAndSave, noSurf, app.authenticate)
 
 	addAliceChainToRoutes(router, dynamic, []MethodPathHandlerFunc{
 		{
@@ -53,9 +54,59 @@ func (app application) routes() http.Handler {
 		},
 		{
 			Method:      http.MethodGet,
-			Path:        "/snippet/view/:id",
+			Path:        "/search",
+			HandlerFunc: app.showSearch,
+		},
+		{
+			Method:      http.MethodGet,
+			Path:        "/tag/:name",
+			HandlerFunc: app.showTag,
+		},
+		{
+			Method:      http.MethodGet,
+			Path:        "/snippet/view/:slug",
 			HandlerFunc: app.showSnippetView,
 		},
+		{
+			Method:      http.MethodGet,
+			Path:        "/snippet/raw/:slug",
+			HandlerFunc: app.showSnippetRaw,
+		},
+		{
+			Method:      http.MethodGet,
+			Path:        "/snippet/download/:slug",
+			HandlerFunc: app.showSnippetDownload,
+		},
+		{
+			Method:      http.MethodGet,
+			Path:        "/snippet/zip/:slug",
+			HandlerFunc: app.showSnippetZip,
+		},
+		{
+			Method:      http.MethodGet,
+			Path:        "/snippet/image/:slug",
+			HandlerFunc: app.showSnippetImage,
+		},
+		{
+			Method:      http.MethodGet,
+			Path:        "/snippet/qr/:slug",
+			HandlerFunc: app.showSnippetQR,
+		},
+		{
+			Method:      http.MethodGet,
+			Path:        "/snippet/diff",
+			HandlerFunc: app.showSnippetDiff,
+		},
+		{
+			Method:      http.MethodGet,
+			Path:        "/snippet/forks/:slug",
+			HandlerFunc: app.showSnippetForks,
+		},
+		{
+			Method:      http.MethodGet,
+			Path:        "/snippet/view/:slug/revision/:number",
+			HandlerFunc: app.showSnippetRevision,
+		},
 		{
 			Method:      http.MethodGet,
 			Path:        "/user/signup",
@@ -78,6 +129,26 @@ func (app application) routes() http.Handler {
 		},
 	})
 
+	// The embed page is shown in frames on other sites, so it has its own
+	// chain. It needs no session, and allowFraming replaces the headers which
+	// stop the rest of the site being framed.
+	embed := alice.New(app.allowFraming)
+	router.Handler(http.MethodGet, "/snippet/embed/:slug", embed.ThenFunc(app.showSnippetEmbed))
+
+	// The oEmbed endpoint only describes snippets anyone can see, so it needs
+	// no session either.
+	router.HandlerFunc(http.MethodGet, "/oembed", app.showOEmbed)
+
+	// The feeds only list public snippets, so they are the same for everyone
+	// and don't need a session.
+	router.HandlerFunc(http.MethodGet, "/feed.atom", app.showFeed)
+	router.HandlerFunc(http.MethodGet, "/feed.rss", app.showFeed)
+
+	// Neither do the sitemap and robots.txt. They have handlers of their own
+	// because the file server only serves what's under /static/.
+	router.HandlerFunc(http.MethodGet, "/sitemap.xml", app.showSitemap)
+	router.HandlerFunc(http.MethodGet, "/robots.txt", app.showRobots)
+
 	// Protected (authenticated-only) application routes, using a new "protected"
 	// middleware chain which includes the requireAuthentication middleware.
 	protected := dynamic.Append(app.requireAuthentication)
@@ -92,6 +163,26 @@ func (app application) routes() http.Handler {
 			Path:        "/snippet/create",
 			HandlerFunc: app.doSnippetCreate,
 		},
+		{
+			Method:      http.MethodGet,
+			Path:        "/snippet/fork/:slug",
+			HandlerFunc: app.showSnippetFork,
+		},
+		{
+			Method:      http.MethodGet,
+			Path:        "/snippet/edit/:slug",
+			HandlerFunc: app.showSnippetEdit,
+		},
+		{
+			Method:      http.MethodPost,
+			Path:        "/snippet/edit/:slug",
+			HandlerFunc: app.doSnippetEdit,
+		},
+		{
+			Method:      http.MethodPost,
+			Path:        "/snippet/delete/:slug",
+			HandlerFunc: app.doSnippetDelete,
+		},
 		{
 			Method:      http.MethodPost,
 			Path:        "/user/logout",
@@ -102,6 +193,26 @@ func (app application) routes() http.Handler {
 			Path:        "/account/view",
 			HandlerFunc: app.showAccountView,
 		},
+		{
+			Method:      http.MethodGet,
+			Path:        "/account/snippets",
+			HandlerFunc: app.showAccountSnippets,
+		},
+		{
+			Method:      http.MethodGet,
+			Path:        "/account/trash",
+			HandlerFunc: app.showAccountTrash,
+		},
+		{
+			Method:      http.MethodPost,
+			Path:        "/account/trash/restore/:slug",
+			HandlerFunc: app.doAccountTrashRestore,
+		},
+		{
+			Method:      http.MethodPost,
+			Path:        "/account/trash/purge/:slug",
+			HandlerFunc: app.doAccountTrashPurge,
+		},
 		{
 			Method:      http.MethodGet,
 			Path:        "/account/password/update",
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"os"
+	"strconv"
+
+	"github.com/cipto-hd/snippetbox/internal/secrets"
+)
+
+// maxSecretFindings is how many of the lines with secrets are listed in the
+// warning on the create and edit forms. The rest are just counted.
+const maxSecretFindings = 20
+
+// A fileSecret is a line which appears to contain a secret, in the title or
+// one of the files on the create and edit forms.
+type fileSecret struct {
+	File string // Empty for the title.
+	secrets.Finding
+}
+
+// A secretsWarning is shown on the create and edit forms when the files
+// appear to contain secrets.
+type secretsWarning struct {
+	Findings []fileSecret // At most maxSecretFindings of them.
+	More     int          // How many more there are.
+	// Fingerprint identifies the findings, by the secrets themselves rather
+	// than their masked text. The form posts it back when the user confirms
+	// that they want to publish anyway, so that adding another secret after
+	// confirming brings the warning back.
+	Fingerprint string
+	// Blocking is set by the -block-secrets flag, in which case the secrets
+	// have to be removed before the snippet can be published.
+	Blocking bool
+}
+
+// loadSecretRules returns the built-in secret rules, followed by any in the
+// file at path.
+func loadSecretRules(path string) ([]secrets.Rule, error) {
+	rules := secrets.Rules
+	if path == "" {
+		return rules, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	extra, err := secrets.ParseRules(f)
+	if err != nil {
+		return nil, err
+	}
+	return append(rules[:len(rules):len(rules)], extra...), nil
+}
+
+// scanSecrets checks the title and files on form for secrets, and returns the
+// warning to show, or nil if none were found.
+func (app *application) scanSecrets(form *snippetCreateForm) *secretsWarning {
+	var found []fileSecret
+	for _, finding := range secrets.Scan(form.Title, app.secretRules) {
+		found = append(found, fileSecret{Finding: finding})
+	}
+	for _, f := range form.Files {
+		for _, finding := range secrets.Scan(f.Content, app.secretRules) {
+			found = append(found, fileSecret{File: f.Name, Finding: finding})
+		}
+	}
+	if len(found) == 0 {
+		return nil
+	}
+
+	h := sha256.New()
+	for _, s := range found {
+		for _, v := range []string{s.File, strconv.Itoa(s.Line), s.Rule, s.Secret} {
+			binary.Write(h, binary.BigEndian, uint64(len(v)))
+			h.Write([]byte(v))
+		}
+	}
+	return &secretsWarning{
+		Findings:    found[:min(len(found), maxSecretFindings)],
+		More:        max(len(found)-maxSecretFindings, 0),
+		Fingerprint: hex.EncodeToString(h.Sum(nil)[:16]),
+		Blocking:    app.blockSecrets,
+	}
+}
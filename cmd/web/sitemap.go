@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/cipto-hd/snippetbox/internal/models"
+)
+
+// sitemapMaxURLs is the most URLs that the sitemap protocol allows in one
+// sitemap. Beyond that /sitemap.xml becomes a sitemap index, pointing to
+// numbered pages. Each URL takes up well under 200 bytes, so the protocol's
+// other limit of 50MB per sitemap is never reached first. It's a variable so
+// that tests can lower it.
+var sitemapMaxURLs = 50000
+
+// sitemapMaxAge is how long crawlers and proxies can cache the sitemap for.
+const sitemapMaxAge = time.Hour
+
+// showSitemap serves the sitemap of every public snippet. When there are too
+// many for a single sitemap, /sitemap.xml serves an index of the pages
+// /sitemap.xml?page=1, ?page=2 and so on. Unlisted, private and
+// burn-after-reading snippets are never included.
+func (app *application) showSitemap(w http.ResponseWriter, r *http.Request) {
+	count, err := app.Snippet.CountListed()
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	pages := max((count+sitemapMaxURLs-1)/sitemapMaxURLs, 1)
+
+	page := 1
+	if p := r.URL.Query().Get("page"); p != "" {
+		page, err = strconv.Atoi(p)
+		if err != nil || page < 1 || page > pages {
+			app.notFound(w)
+			return
+		}
+	} else if pages > 1 {
+		w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+		w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(sitemapMaxAge.Seconds())))
+		writeSitemapIndex(w, app.baseURL, pages)
+		return
+	}
+
+	// The sitemap is streamed straight from the database, so once the first
+	// entry has been written it's too late to report an error with a status
+	// code. The error is logged and the connection aborted instead, so that
+	// crawlers see a failed fetch rather than a short sitemap.
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(sitemapMaxAge.Seconds())))
+	bw := bufio.NewWriter(w)
+	io.WriteString(bw, xml.Header+`<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">`+"\n")
+	started := false
+	err = app.Snippet.EachListed((page-1)*sitemapMaxURLs, sitemapMaxURLs, func(e models.SitemapEntry) error {
+		started = true
+		io.WriteString(bw, "  <url><loc>")
+		xml.EscapeText(bw, []byte(app.baseURL+"/snippet/view/"+e.Slug))
+		io.WriteString(bw, "</loc><lastmod>"+e.Updated.UTC().Format(time.RFC3339)+"</lastmod></url>\n")
+		return nil
+	})
+	if err != nil {
+		if !started {
+			// Nothing has left the buffer yet, so it can just be dropped.
+			app.serverError(w, err)
+			return
+		}
+		app.errorLog.Output(2, fmt.Sprintf("sitemap page %d: %s", page, err))
+		panic(http.ErrAbortHandler)
+	}
+	io.WriteString(bw, "</urlset>\n")
+	bw.Flush()
+}
+
+// writeSitemapIndex writes a sitemap index listing the given number of
+// sitemap pages.
+func writeSitemapIndex(w io.Writer, baseURL string, pages int) {
+	bw := bufio.NewWriter(w)
+	io.WriteString(bw, xml.Header+`<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">`+"\n")
+	for page := 1; page <= pages; page++ {
+		io.WriteString(bw, "  <sitemap><loc>")
+		xml.EscapeText(bw, []byte(baseURL+"/sitemap.xml?page="+strconv.Itoa(page)))
+		io.WriteString(bw, "</loc></sitemap>\n")
+	}
+	io.WriteString(bw, "</sitemapindex>\n")
+	bw.Flush()
+}
+
+// defaultRobots returns the robots.txt served when the operator doesn't
+// give one. It keeps crawlers to the pages worth indexing: the snippets
+// themselves and the listings that lead to them, but not the copies of each
+// snippet in other formats, or the pages which need an account.
+func defaultRobots(baseURL string) string {
+	return `User-agent: *
+Disallow: /account/
+Disallow: /user/
+Disallow: /search
+Disallow: /snippet/create
+Disallow: /snippet/edit/
+Disallow: /snippet/fork/
+Disallow: /snippet/diff
+Disallow: /snippet/raw/
+Disallow: /snippet/download/
+Disallow: /snippet/zip/
+Disallow: /snippet/embed/
+Disallow: /snippet/qr/
+Disallow: /snippet/image/
+
+Sitemap: ` + baseURL + `/sitemap.xml
+`
+}
+
+// loadRobots returns the contents of the robots.txt file at path, or the
+// default robots.txt if path is empty. The file is read once, at startup, and
+// served exactly as it is.
+func loadRobots(path, baseURL string) (string, error) {
+	if path == "" {
+		return defaultRobots(baseURL), nil
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// showRobots serves robots.txt.
+func (app *application) showRobots(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(sitemapMaxAge.Seconds())))
+	io.WriteString(w, app.robots)
+}
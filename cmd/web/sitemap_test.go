@@ -0,0 +1,27 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cipto-hd/snippetbox/internal/assert"
+)
+
+func TestLoadRobots(t *testing.T) {
+	robots, err := loadRobots("", "https://snippetbox.example")
+	assert.NilError(t, err)
+	assert.Equal(t, robots, defaultRobots("https://snippetbox.example"))
+
+	path := filepath.Join(t.TempDir(), "robots.txt")
+	err = os.WriteFile(path, []byte("User-agent: *\nDisallow: /\n"), 0o644)
+	assert.NilError(t, err)
+	robots, err = loadRobots(path, "https://snippetbox.example")
+	assert.NilError(t, err)
+	assert.Equal(t, robots, "User-agent: *\nDisallow: /\n")
+
+	_, err = loadRobots(filepath.Join(t.TempDir(), "missing.txt"), "https://snippetbox.example")
+	if err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
@@ -1,14 +1,23 @@
 package main
 
 import (
+	"fmt"
+	"html"
 	"html/template"
 	"io/fs"
 	"net/http"
 	"path/filepath"
+	"regexp"
+	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/justinas/nosurf"
 
+	"github.com/cipto-hd/snippetbox/internal/diff"
+	"github.com/cipto-hd/snippetbox/internal/highlight"
+	"github.com/cipto-hd/snippetbox/internal/markdown"
 	"github.com/cipto-hd/snippetbox/internal/models"
 	"github.com/cipto-hd/snippetbox/ui"
 )
@@ -18,14 +27,59 @@ import (
 // At the moment it only contains one field, but we'll add more
 // to it as the build progresses.
 type templateData struct {
-	CurrentYear     int
-	Snippet         *models.Snippet
-	Snippets        []*models.Snippet
-	Form            any
-	Flash           string
-	IsAuthenticated bool
-	CSRFToken       string // Add a CSRFToken field.
-	User            *models.User
+	CurrentYear         int
+	Snippet             *models.Snippet
+	Snippets            []*models.Snippet
+	Page                *models.Page
+	Search              *models.SearchPage
+	Tag                 string
+	Revision            *models.Revision
+	Revisions           []*models.Revision
+	Parent              *models.Snippet
+	Forks               []*models.Snippet
+	Diff                *snippetDiff
+	Secrets             *secretsWarning
+	Form                any
+	Flash               string
+	IsAuthenticated     bool
+	AuthenticatedUserID int
+	CSRFToken           string // Add a CSRFToken field.
+	User                *models.User
+	TrashDays           int
+	BaseURL             string // The -base-url flag, without a trailing slash.
+}
+
+// A snippetDiff holds the two versions compared on the diff page, and the
+// differences between each of their files.
+type snippetDiff struct {
+	From, To       *diffSource
+	Files          []*fileDiff
+	Added, Deleted int // The totals for all the files.
+}
+
+// Identical reports whether there were no differences in any file.
+func (d *snippetDiff) Identical() bool {
+	return d.Added == 0 && d.Deleted == 0
+}
+
+// A fileDiff holds the differences between a file in two versions. Old or
+// New is nil for a file which is only in one of them.
+type fileDiff struct {
+	Old, New *models.File
+	Result   *diff.Result
+}
+
+// Name returns the name to show for the file, which shows a renamed main
+// file as "old → new".
+func (d *fileDiff) Name() string {
+	switch {
+	case d.Old == nil:
+		return d.New.Name
+	case d.New == nil, d.Old.Name == d.New.Name:
+		return d.Old.Name
+	default:
+		return d.Old.Name + " → " + d.New.Name
+	}
 }
 
 // Create a humanDate function which returns a nicely formatted string
@@ -39,11 +93,185 @@ func humanDate(t time.Time) string {
 	return t.UTC().Format("02 Jan 2006 at 15:04")
 }
 
+// countdown returns roughly how long is left until t, in its two largest
+// units, for example "3 days 4 hours" or "12 minutes". Times in the past give
+// "0 minutes". ui/static/js/main.js keeps the same text up to date in the
+// browser.
+func countdown(t time.Time) string {
+	left := time.Until(t)
+	if left < time.Minute {
+		if left > 0 {
+			return "less than a minute"
+		}
+		return "0 minutes"
+	}
+	units := []struct {
+		name string
+		d    time.Duration
+	}{
+		{"day", 24 * time.Hour},
+		{"hour", time.Hour},
+		{"minute", time.Minute},
+	}
+	// Take the largest unit with a non-zero count, plus the next unit down if
+	// its count isn't zero either.
+	var parts []string
+	for _, u := range units {
+		n := int(left / u.d)
+		left -= time.Duration(n) * u.d
+		if n == 0 {
+			if len(parts) > 0 {
+				break
+			}
+			continue
+		}
+		plural := "s"
+		if n == 1 {
+			plural = ""
+		}
+		parts = append(parts, fmt.Sprintf("%d %s%s", n, u.name, plural))
+		if len(parts) == 2 {
+			break
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// searchTerms splits a search query into the words that MySQL's FULLTEXT
+// search will match on. Words shorter than three characters are dropped, just
+// as InnoDB ignores them by default.
+func searchTerms(query string) []string {
+	var terms []string
+	for _, word := range strings.FieldsFunc(query, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if utf8.RuneCountInString(word) >= 3 {
+			terms = append(terms, regexp.QuoteMeta(word))
+		}
+	}
+	return terms
+}
+
+// searchTermsRX returns a case-insensitive regular expression matching any of
+// the words in a search query, or nil if there are none.
+func searchTermsRX(query string) *regexp.Regexp {
+	terms := searchTerms(query)
+	if len(terms) == 0 {
+		return nil
+	}
+	return regexp.MustCompile(`(?i)` + strings.Join(terms, "|"))
+}
+
+// excerptLength is the number of characters shown in a search result excerpt.
+const excerptLength = 160
+
+// excerpt returns a fragment of text of roughly excerptLength characters,
+// centred where possible on the first word of the query that it contains.
+func excerpt(text, query string) string {
+	runes := []rune(text)
+	start := 0
+	if rx := searchTermsRX(query); rx != nil {
+		if loc := rx.FindStringIndex(text); loc != nil {
+			start = max(utf8.RuneCountInString(text[:loc[0]])-excerptLength/3, 0)
+		}
+	}
+	end := min(start+excerptLength, len(runes))
+	fragment := string(runes[start:end])
+	if start > 0 {
+		fragment = "…" + fragment
+	}
+	if end < len(runes) {
+		fragment += "…"
+	}
+	return fragment
+}
+
+// highlightTerms HTML-escapes text and wraps each occurrence of a word from the
+// query in a <mark> element.
+func highlightTerms(text, query string) template.HTML {
+	rx := searchTermsRX(query)
+	if rx == nil {
+		return template.HTML(template.HTMLEscapeString(text))
+	}
+	var b strings.Builder
+	last := 0
+	for _, loc := range rx.FindAllStringIndex(text, -1) {
+		b.WriteString(template.HTMLEscapeString(text[last:loc[0]]))
+		b.WriteString("<mark>")
+		b.WriteString(template.HTMLEscapeString(text[loc[0]:loc[1]]))
+		b.WriteString("</mark>")
+		last = loc[1]
+	}
+	b.WriteString(template.HTMLEscapeString(text[last:]))
+	return template.HTML(b.String())
+}
+
+// add returns the sum of two integers, for simple arithmetic in templates such
+// as working out the next page number.
+func add(a, b int) int {
+	return a + b
+}
+
+// embedCode returns the HTML for showing a snippet's embed page in a frame on
+// another site.
+func embedCode(baseURL string, s *models.Snippet) string {
+	return iframeHTML(baseURL, s, "100%", embedHeight)
+}
+
+// embedHeight is the height in pixels of an embedded snippet's frame.
+const embedHeight = 300
+
+// iframeHTML returns an iframe element showing a snippet's embed page at the
+// given width, in pixels or as a percentage, and height in pixels.
+func iframeHTML(baseURL string, s *models.Snippet, width string, height int) string {
+	return fmt.Sprintf(`<iframe src="%s/snippet/embed/%s" width="%s" height="%d" frameborder="0" title="%s"></iframe>`,
+		html.EscapeString(baseURL), s.Slug, width, height, html.EscapeString(s.Title))
+}
+
+// previewable reports whether a snippet's title and content can be shown in
+// link previews, such as the cards shown when a link is pasted into chat.
+// Private snippets can't, and nor can burn-after-reading ones, since anyone
+// the link is shared with would see the preview.
+func previewable(s *models.Snippet) bool {
+	return s.Visibility != models.VisibilityPrivate && s.MaxViews == 0
+}
+
+// summary describes a snippet in a line for link previews, with its language
+// and the start of its content, like "Go: package main import ...".
+func summary(s *models.Snippet) string {
+	label := highlight.Text.Label
+	if lang := highlight.Lookup(s.Language); lang != nil {
+		label = lang.Label
+	}
+	return label + ": " + excerpt(strings.Join(strings.Fields(s.Content), " "), "")
+}
+
 // Initialize a template.FuncMap object and store it in a global variable. This is
 // essentially a string-keyed map which acts as a lookup between the names of our
 // custom template functions and the functions themselves.
 var functions = template.FuncMap{
-	"humanDate": humanDate,
+	"humanDate":      humanDate,
+	"excerpt":        excerpt,
+	"highlightTerms": highlightTerms,
+	"add":            add,
+	"countdown":      countdown,
+	// highlightCode splits a snippet's content into syntax highlighted lines.
+	"highlightCode": highlight.Lines,
+	// markdown renders markdown to sanitized HTML.
+	"markdown": markdown.Render,
+	// files returns the files of a snippet, main file first.
+	"files": snippetFiles,
+	// embedCode returns the HTML for embedding a snippet in another site.
+	"embedCode": embedCode,
+	// previewable and summary are used for the link preview meta tags.
+	"previewable": previewable,
+	"summary":     summary,
+	// lookupLanguage returns the named language, or nil if it's unknown.
+	"lookupLanguage": highlight.Lookup,
+	// visibilities returns the visibility levels offered on the create form.
+	"visibilities": func() []models.Visibility { return models.Visibilities },
+	// languages returns the languages offered in the language picker.
+	"languages": func() []*highlight.Language { return highlight.Languages },
 }
 
 func newTemplateCache() (map[string]*template.Template, error) {
@@ -67,6 +295,7 @@ func newTemplateCache() (map[string]*template.Template, error) {
 		// want to parse.
 		patterns := []string{
 			"html/base.tmpl",
+			"html/frame.tmpl",
 			"html/partials/*.tmpl",
 			page,
 		}
@@ -106,10 +335,15 @@ func newTemplateCache() (map[string]*template.Template, error) {
 // struct initialized with the current year. Note that we're not using the
 // *http.Request parameter here at the moment, but we will do later in the book.
 func (app *application) newTemplateData(r *http.Request) *templateData {
-	return &templateData{
+	data := &templateData{
 		CurrentYear:     time.Now().Year(),
 		Flash:           app.sessionManager.PopString(r.Context(), "flash"),
 		IsAuthenticated: app.isAuthenticated(r),
 		CSRFToken:       nosurf.Token(r),
+		BaseURL:         app.baseURL,
 	}
+	// Only expose the user ID once the authenticate middleware has confirmed
+	// that the user still exists.
+	data.AuthenticatedUserID = app.authenticatedUserID(r)
+	return data
 }
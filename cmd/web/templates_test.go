@@ -1,6 +1,7 @@
 package main
 
 import (
+	"strings"
 	"testing"
 	"time"
 
@@ -50,3 +51,128 @@ func TestHumanDate(t *testing.T) {
 		})
 	}
 }
+
+func TestHighlightTerms(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		query string
+		want  string
+	}{
+		{
+			name:  "Single match",
+			text:  "An old silent pond",
+			query: "pond",
+			want:  "An old silent <mark>pond</mark>",
+		},
+		{
+			name:  "Case insensitive",
+			text:  "An old silent Pond",
+			query: "POND silent",
+			want:  "An old <mark>silent</mark> <mark>Pond</mark>",
+		},
+		{
+			name:  "Escapes HTML",
+			text:  "<script>pond</script>",
+			query: "pond",
+			want:  "&lt;script&gt;<mark>pond</mark>&lt;/script&gt;",
+		},
+		{
+			name:  "Short words ignored",
+			text:  "An old pond",
+			query: "an",
+			want:  "An old pond",
+		},
+		{
+			name:  "Regexp characters",
+			text:  "a.*b pond",
+			query: ".*",
+			want:  "a.*b pond",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, string(highlightTerms(tt.text, tt.query)), tt.want)
+		})
+	}
+}
+
+func TestExcerpt(t *testing.T) {
+	long := strings.Repeat("x", 200) + " pond " + strings.Repeat("y", 200)
+	tests := []struct {
+		name  string
+		text  string
+		query string
+		want  string
+	}{
+		{
+			name:  "Short text",
+			text:  "An old silent pond",
+			query: "pond",
+			want:  "An old silent pond",
+		},
+		{
+			name:  "Centred on match",
+			text:  long,
+			query: "pond",
+			want:  "…" + strings.Repeat("x", 52) + " pond " + strings.Repeat("y", 102) + "…",
+		},
+		{
+			name:  "No match",
+			text:  long,
+			query: "frog",
+			want:  strings.Repeat("x", 160) + "…",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, excerpt(tt.text, tt.query), tt.want)
+		})
+	}
+}
+
+func TestCountdown(t *testing.T) {
+	// Add half a minute to each duration so that the time taken to run the
+	// test can't tip the countdown into the unit below.
+	tests := []struct {
+		name string
+		in   time.Duration
+		want string
+	}{
+		{
+			name: "Past",
+			in:   -time.Hour,
+			want: "0 minutes",
+		},
+		{
+			name: "Seconds",
+			in:   0,
+			want: "less than a minute",
+		},
+		{
+			name: "Minutes",
+			in:   12 * time.Minute,
+			want: "12 minutes",
+		},
+		{
+			name: "One hour",
+			in:   time.Hour,
+			want: "1 hour",
+		},
+		{
+			name: "Days and hours",
+			in:   3*24*time.Hour + 4*time.Hour + 5*time.Minute,
+			want: "3 days 4 hours",
+		},
+		{
+			name: "Days and minutes",
+			in:   2*24*time.Hour + 5*time.Minute,
+			want: "2 days",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, countdown(time.Now().Add(tt.in+30*time.Second)), tt.want)
+		})
+	}
+}
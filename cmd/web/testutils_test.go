@@ -17,6 +17,7 @@ import (
 	"github.com/go-playground/form/v4"
 
 	"github.com/cipto-hd/snippetbox/internal/models/mocks"
+	"github.com/cipto-hd/snippetbox/internal/secrets"
 )
 
 // Create a newTestApplication helper which returns an instance of our
@@ -27,6 +28,11 @@ func newTestApplication(t *testing.T) *application {
 	if err != nil {
 		t.Fatal(err)
 	}
+	// And the renderer for images of snippets, with a small cache.
+	codeImages, err := newCodeImageRenderer(1024 * 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
 	// And a form decoder.
 	formDecoder := form.NewDecoder()
 	// And a session manager instance. Note that we use the same settings as
@@ -41,9 +47,19 @@ func newTestApplication(t *testing.T) *application {
 		infoLog:        log.New(io.Discard, "", 0),
 		Snippet:        &mocks.SnippetModel{}, // Use the mock.
 		User:           &mocks.UserModel{},    // Use the mock.
+		Session:        &mocks.SessionModel{}, // Use the mock.
 		templateCache:  templateCache,
 		formDecoder:    formDecoder,
 		sessionManager: sessionManager,
+		trashDays:      30,
+		numericIDs:     true,
+		maxFileSize:    64 * 1024,
+		maxSnippetSize: 256 * 1024,
+		baseURL:        "https://snippetbox.example",
+		embedOrigins:   []string{"https://wiki.example.com"},
+		robots:         defaultRobots("https://snippetbox.example"),
+		codeImages:     codeImages,
+		secretRules:    secrets.Rules,
 	}
 }
 
@@ -78,7 +94,20 @@ func newTestServer(t *testing.T, h http.Handler) *testServer {
 // request to a given url path using the test server client, and returns the
 // response status code, headers and body.
 func (ts *testServer) get(t *testing.T, urlPath string) (int, http.Header, string) {
-	rs, err := ts.Client().Get(ts.URL + urlPath)
+	return ts.getWithHeader(t, urlPath, nil)
+}
+
+// getWithHeader makes a GET request like get(), but with extra request
+// headers, for example If-None-Match.
+func (ts *testServer) getWithHeader(t *testing.T, urlPath string, header http.Header) (int, http.Header, string) {
+	req, err := http.NewRequest(http.MethodGet, ts.URL+urlPath, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	rs, err := ts.Client().Do(req)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -125,3 +154,17 @@ func (ts *testServer) postForm(t *testing.T, urlPath string, form url.Values) (i
 	// Return the response status, headers and body.
 	return rs.StatusCode, rs.Header, string(body)
 }
+
+// Create a login method which logs the test server client in as the mock
+// user, so that subsequent requests are made as an authenticated user.
+func (ts *testServer) login(t *testing.T) {
+	_, _, body := ts.get(t, "/user/login")
+	form := url.Values{}
+	form.Add("email", "alice@example.com")
+	form.Add("password", "pa$$word")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+	code, _, _ := ts.postForm(t, "/user/login", form)
+	if code != http.StatusSeeOther {
+		t.Fatalf("login failed with status %d", code)
+	}
+}
@@ -0,0 +1,58 @@
+package codeimage
+
+import (
+	"container/list"
+	"sync"
+)
+
+// A cache holds up to max bytes of images by key, dropping the least recently
+// used ones to make room.
+type cache struct {
+	mu    sync.Mutex
+	max   int
+	size  int
+	order *list.List // Of *cacheEntry, most recently used first.
+	items map[string]*list.Element
+}
+
+type cacheEntry struct {
+	key  string
+	data []byte
+}
+
+func newCache(max int) *cache {
+	return &cache{max: max, order: list.New(), items: map[string]*list.Element{}}
+}
+
+func (c *cache) get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(e)
+	return e.Value.(*cacheEntry).data, true
+}
+
+// add stores data under key. Anything bigger than the whole cache isn't
+// stored at all.
+func (c *cache) add(key string, data []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(data) > c.max {
+		return
+	}
+	if _, ok := c.items[key]; ok {
+		return
+	}
+	for c.size+len(data) > c.max {
+		e := c.order.Back()
+		c.order.Remove(e)
+		old := e.Value.(*cacheEntry)
+		delete(c.items, old.key)
+		c.size -= len(old.data)
+	}
+	c.items[key] = c.order.PushFront(&cacheEntry{key, data})
+	c.size += len(data)
+}
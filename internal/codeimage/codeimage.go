@@ -0,0 +1,302 @@
+// Package codeimage draws highlighted code as a PNG image, for pasting into
+// slides and chat. The code is drawn in a window, with a title bar and
+// optional line numbers, using the colours of a Theme. Only Go's image
+// packages and golang.org/x/image/font are used, with the fonts passed in by
+// the caller, so the output is the same wherever it's rendered.
+package codeimage
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"strconv"
+	"sync"
+	"unicode/utf8"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+
+	"github.com/cipto-hd/snippetbox/internal/highlight"
+)
+
+// MaxLines and MaxColumns limit the size of an image. Longer code is cut
+// short, with an ellipsis to show where.
+const (
+	MaxLines   = 200
+	MaxColumns = 120
+)
+
+// Images are drawn at twice their nominal size, so that they stay sharp on
+// high-resolution screens and projectors. The sizes below are nominal.
+const (
+	scale     = 2
+	fontSize  = 14
+	padding   = 20
+	titleBar  = 36
+	minWidth  = 480
+	tabWidth  = 4
+	dotRadius = 6
+)
+
+// errNoGlyphs is returned by New() for a font which can't draw plain text.
+var errNoGlyphs = errors.New("codeimage: font has no glyph for M")
+
+// version is part of every image's key, so that changing how images are
+// drawn doesn't serve stale ones from a cache. Bump it with any such change.
+const version = 1
+
+// Options describes an image.
+type Options struct {
+	Title       string
+	Language    string
+	Content     string
+	Theme       *Theme
+	LineNumbers bool
+}
+
+// Key returns a hash of everything which affects the image for opts, for use
+// as a cache key and ETag. Options with the same key give identical images.
+func Key(opts Options) string {
+	h := sha256.New()
+	// Each string is written with its length, so that moving text from one
+	// field to the next changes the hash.
+	for _, s := range []string{strconv.Itoa(version), opts.Theme.Name, strconv.FormatBool(opts.LineNumbers), opts.Title, opts.Language, opts.Content} {
+		binary.Write(h, binary.BigEndian, uint64(len(s)))
+		h.Write([]byte(s))
+	}
+	return hex.EncodeToString(h.Sum(nil)[:16])
+}
+
+// A Renderer draws images with a pair of monospaced fonts, and keeps the most
+// recently drawn ones in a cache. It's safe for concurrent use.
+type Renderer struct {
+	// Font faces aren't safe for concurrent use, so drawing is serialized
+	// by mu. The cache has its own lock, so cache hits don't wait for it.
+	mu            sync.Mutex
+	regular, bold font.Face
+	advance       int // The width of every character, in pixels.
+	ascent        int
+	lineHeight    int
+	cache         *cache
+}
+
+// New returns a Renderer using the given regular and bold monospaced fonts, in
+// TrueType or OpenType format, which caches up to cacheSize bytes of images.
+func New(regular, bold []byte, cacheSize int) (*Renderer, error) {
+	rf, err := newFace(regular)
+	if err != nil {
+		return nil, err
+	}
+	bf, err := newFace(bold)
+	if err != nil {
+		return nil, err
+	}
+	advance, ok := rf.GlyphAdvance('M')
+	if !ok {
+		return nil, errNoGlyphs
+	}
+	m := rf.Metrics()
+	return &Renderer{
+		regular:    rf,
+		bold:       bf,
+		advance:    advance.Ceil(),
+		ascent:     m.Ascent.Ceil(),
+		lineHeight: m.Height.Ceil() * 4 / 3,
+		cache:      newCache(cacheSize),
+	}, nil
+}
+
+func newFace(data []byte) (font.Face, error) {
+	f, err := opentype.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	return opentype.NewFace(f, &opentype.FaceOptions{
+		Size:    fontSize,
+		DPI:     72 * scale,
+		Hinting: font.HintingFull,
+	})
+}
+
+// Render returns the PNG image for opts, and its key. A cached image is
+// returned if there is one.
+func (r *Renderer) Render(opts Options) ([]byte, string, error) {
+	if opts.Theme == nil {
+		opts.Theme = Light
+	}
+	key := Key(opts)
+	if b, ok := r.cache.get(key); ok {
+		return b, key, nil
+	}
+
+	r.mu.Lock()
+	img := r.draw(opts)
+	r.mu.Unlock()
+
+	var buf bytes.Buffer
+	err := png.Encode(&buf, img)
+	if err != nil {
+		return nil, "", err
+	}
+	r.cache.add(key, buf.Bytes())
+	return buf.Bytes(), key, nil
+}
+
+// A cell is a character to draw, and how.
+type cell struct {
+	r    rune
+	face font.Face
+	c    color.Color
+}
+
+// draw draws the image for opts.
+func (r *Renderer) draw(opts Options) image.Image {
+	t := opts.Theme
+	lines, numbered := r.layout(opts)
+	gutter := 0
+	if opts.LineNumbers {
+		gutter = len(strconv.Itoa(numbered)) + 2
+	}
+	columns := 0
+	for _, line := range lines {
+		columns = max(columns, len(line))
+	}
+
+	width := max(2*padding*scale+(gutter+columns)*r.advance, minWidth*scale)
+	height := titleBar*scale + 2*padding*scale + len(lines)*r.lineHeight
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(t.Background), image.Point{}, draw.Src)
+
+	// The title bar, with three dots for the window buttons, and the title
+	// centred, cut short if it would run into them.
+	bar := image.Rect(0, 0, width, titleBar*scale)
+	draw.Draw(img, bar, image.NewUniform(t.TitleBar), image.Point{}, draw.Src)
+	for i, c := range t.Dots {
+		fillCircle(img, (padding+i*3*dotRadius)*scale, titleBar*scale/2, dotRadius*scale, c)
+	}
+	title := []rune(opts.Title)
+	room := (width - 2*(padding+9*dotRadius)*scale) / r.advance
+	title = truncate(title, room)
+	x := (width - len(title)*r.advance) / 2
+	y := (titleBar*scale-r.lineHeight)/2 + r.baseline()
+	for i, ch := range title {
+		r.drawRune(img, x+i*r.advance, y, cell{ch, r.bold, t.Title})
+	}
+
+	// The code, with the line numbers right-aligned in a gutter.
+	top := titleBar*scale + padding*scale
+	for i, line := range lines {
+		y := top + i*r.lineHeight + r.baseline()
+		x := padding * scale
+		if opts.LineNumbers && i < numbered {
+			n := strconv.Itoa(i + 1)
+			for j, ch := range n {
+				col := gutter - 2 - len(n) + j
+				r.drawRune(img, x+col*r.advance, y, cell{ch, r.regular, t.LineNumber})
+			}
+		}
+		if opts.LineNumbers {
+			x += gutter * r.advance
+		}
+		for col, c := range line {
+			r.drawRune(img, x+col*r.advance, y, c)
+		}
+	}
+	return img
+}
+
+// layout splits the content of opts into lines of cells, with tabs expanded
+// and the lines and columns beyond the limits replaced by ellipses. It also
+// returns how many of the lines are code, and so get a line number.
+func (r *Renderer) layout(opts Options) ([][]cell, int) {
+	t := opts.Theme
+	spans := highlight.Spans(opts.Language, opts.Content)
+	cut := len(spans) > MaxLines
+	if cut {
+		spans = spans[:MaxLines-1]
+	}
+	lines := make([][]cell, 0, len(spans)+1)
+	for _, line := range spans {
+		var cells []cell
+		for _, span := range line {
+			face := r.regular
+			if span.Class == highlight.Keyword {
+				face = r.bold
+			}
+			c := t.color(span.Class)
+			for _, ch := range span.Text {
+				if ch == '\t' {
+					for n := tabWidth - len(cells)%tabWidth; n > 0; n-- {
+						cells = append(cells, cell{' ', face, c})
+					}
+					continue
+				}
+				cells = append(cells, cell{ch, face, c})
+			}
+		}
+		if len(cells) > MaxColumns {
+			cells = append(cells[:MaxColumns-1], cell{'…', r.regular, t.LineNumber})
+		}
+		lines = append(lines, cells)
+	}
+	numbered := len(lines)
+	if cut {
+		lines = append(lines, []cell{{'…', r.regular, t.LineNumber}})
+	}
+	return lines, numbered
+}
+
+// baseline returns the distance from the top of a line to its baseline,
+// which puts the text in the middle of the line's height.
+func (r *Renderer) baseline() int {
+	m := r.regular.Metrics()
+	return (r.lineHeight-m.Height.Ceil())/2 + r.ascent
+}
+
+// drawRune draws a single character with its left edge at x and its baseline
+// at y. Control characters and other runes without a glyph are left blank.
+func (r *Renderer) drawRune(img draw.Image, x, y int, c cell) {
+	if c.r == ' ' || c.r < ' ' || c.r == utf8.RuneError {
+		return
+	}
+	if _, ok := c.face.GlyphAdvance(c.r); !ok {
+		return
+	}
+	d := font.Drawer{
+		Dst:  img,
+		Src:  image.NewUniform(c.c),
+		Face: c.face,
+		Dot:  fixed.P(x, y),
+	}
+	d.DrawString(string(c.r))
+}
+
+// truncate cuts s down to n runes, ending with an ellipsis if anything was
+// cut off.
+func truncate(s []rune, n int) []rune {
+	if len(s) <= n {
+		return s
+	}
+	if n < 1 {
+		return nil
+	}
+	return append(s[:n-1:n-1], '…')
+}
+
+// fillCircle draws a filled circle of radius r centred on (cx, cy).
+func fillCircle(img draw.Image, cx, cy, r int, c color.Color) {
+	for y := -r; y <= r; y++ {
+		for x := -r; x <= r; x++ {
+			if x*x+y*y <= r*r {
+				img.Set(cx+x, cy+y, c)
+			}
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package codeimage
+
+import (
+	"bytes"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/cipto-hd/snippetbox/internal/assert"
+	"github.com/cipto-hd/snippetbox/ui"
+)
+
+func newTestRenderer(t *testing.T, cacheSize int) *Renderer {
+	regular, err := ui.Files.ReadFile("fonts/Go-Mono.ttf")
+	assert.NilError(t, err)
+	bold, err := ui.Files.ReadFile("fonts/Go-Mono-Bold.ttf")
+	assert.NilError(t, err)
+	r, err := New(regular, bold, cacheSize)
+	assert.NilError(t, err)
+	return r
+}
+
+func TestRender(t *testing.T) {
+	r := newTestRenderer(t, 1024*1024)
+	short := Options{Title: "Hello", Language: "go", Content: "package main\n", Theme: Dark, LineNumbers: true}
+	long := short
+	long.Content = strings.Repeat(strings.Repeat("x", 500)+"\n", 1000)
+
+	sizes := map[string][2]int{}
+	for name, opts := range map[string]Options{"short": short, "long": long} {
+		b, key, err := r.Render(opts)
+		assert.NilError(t, err)
+		assert.Equal(t, key, Key(opts))
+		img, err := png.Decode(bytes.NewReader(b))
+		assert.NilError(t, err)
+		sizes[name] = [2]int{img.Bounds().Dx(), img.Bounds().Dy()}
+	}
+
+	// Short code gets the minimum width; long code is cut down to the
+	// limits, so its image is only so big.
+	assert.Equal(t, sizes["short"][0], minWidth*scale)
+	wantWidth := 2*padding*scale + (len("200")+2+MaxColumns)*r.advance
+	wantHeight := titleBar*scale + 2*padding*scale + MaxLines*r.lineHeight
+	assert.Equal(t, sizes["long"], [2]int{wantWidth, wantHeight})
+}
+
+func TestKey(t *testing.T) {
+	opts := Options{Title: "ab", Language: "go", Content: "c", Theme: Light}
+	keys := map[string]bool{Key(opts): true}
+	for _, change := range []func(o *Options){
+		func(o *Options) { o.Theme = Dark },
+		func(o *Options) { o.LineNumbers = true },
+		func(o *Options) { o.Content = "d" },
+		func(o *Options) { o.Language = "python" },
+		// Text moved from one field to another must change the key too.
+		func(o *Options) { o.Title, o.Language = "a", "bgo" },
+	} {
+		o := opts
+		change(&o)
+		k := Key(o)
+		if keys[k] {
+			t.Errorf("options %+v have a key which isn't unique", o)
+		}
+		keys[k] = true
+	}
+	assert.Equal(t, Key(opts), Key(opts))
+}
+
+func TestCache(t *testing.T) {
+	c := newCache(10)
+	c.add("a", []byte("aaaa"))
+	c.add("b", []byte("bbbb"))
+	// Using a makes b the least recently used, so b is dropped for c.
+	_, ok := c.get("a")
+	assert.Equal(t, ok, true)
+	c.add("c", []byte("cccc"))
+	_, ok = c.get("b")
+	assert.Equal(t, ok, false)
+	data, ok := c.get("a")
+	assert.Equal(t, ok, true)
+	assert.Equal(t, string(data), "aaaa")
+	assert.Equal(t, c.size, 8)
+
+	// Anything bigger than the whole cache isn't kept.
+	c.add("d", []byte("ddddddddddd"))
+	_, ok = c.get("d")
+	assert.Equal(t, ok, false)
+	assert.Equal(t, c.size, 8)
+}
@@ -0,0 +1,90 @@
+package codeimage
+
+import (
+	"image/color"
+
+	"github.com/cipto-hd/snippetbox/internal/highlight"
+)
+
+// A Theme is the set of colours an image is drawn in.
+type Theme struct {
+	// Name is how the theme is chosen, like "dark".
+	Name       string
+	Background color.RGBA
+	Foreground color.RGBA
+	LineNumber color.RGBA
+	TitleBar   color.RGBA
+	Title      color.RGBA
+	// Dots are the colours of the window buttons in the title bar.
+	Dots []color.RGBA
+	// Classes holds the colours of highlighted code, by highlight class.
+	// Classes without one are drawn in the Foreground colour.
+	Classes map[string]color.RGBA
+}
+
+// color returns the colour for code of the given highlight class.
+func (t *Theme) color(class string) color.RGBA {
+	if c, ok := t.Classes[class]; ok {
+		return c
+	}
+	return t.Foreground
+}
+
+// windowDots are the colours of the close, minimise and maximise buttons.
+var windowDots = []color.RGBA{
+	{0xFF, 0x5F, 0x57, 0xFF},
+	{0xFE, 0xBC, 0x2E, 0xFF},
+	{0x28, 0xC8, 0x40, 0xFF},
+}
+
+// Light matches the colours of code on the site.
+var Light = &Theme{
+	Name:       "light",
+	Background: color.RGBA{0xFF, 0xFF, 0xFF, 0xFF},
+	Foreground: color.RGBA{0x34, 0x49, 0x5E, 0xFF},
+	LineNumber: color.RGBA{0xB0, 0xB3, 0xB8, 0xFF},
+	TitleBar:   color.RGBA{0xF1, 0xF3, 0xF6, 0xFF},
+	Title:      color.RGBA{0x34, 0x49, 0x5E, 0xFF},
+	Dots:       windowDots,
+	Classes: map[string]color.RGBA{
+		highlight.Comment:  {0x95, 0xA5, 0xA6, 0xFF},
+		highlight.Keyword:  {0x8E, 0x44, 0xAD, 0xFF},
+		highlight.Number:   {0xD3, 0x54, 0x00, 0xFF},
+		highlight.String:   {0x27, 0xAE, 0x60, 0xFF},
+		highlight.Type:     {0x29, 0x80, 0xB9, 0xFF},
+		highlight.Variable: {0xC0, 0x39, 0x2B, 0xFF},
+	},
+}
+
+// Dark is a dark theme, for slides with a dark background.
+var Dark = &Theme{
+	Name:       "dark",
+	Background: color.RGBA{0x28, 0x2C, 0x34, 0xFF},
+	Foreground: color.RGBA{0xAB, 0xB2, 0xBF, 0xFF},
+	LineNumber: color.RGBA{0x5C, 0x63, 0x70, 0xFF},
+	TitleBar:   color.RGBA{0x21, 0x25, 0x2B, 0xFF},
+	Title:      color.RGBA{0xD7, 0xDA, 0xE0, 0xFF},
+	Dots:       windowDots,
+	Classes: map[string]color.RGBA{
+		highlight.Comment:  {0x7F, 0x84, 0x8E, 0xFF},
+		highlight.Keyword:  {0xC6, 0x78, 0xDD, 0xFF},
+		highlight.Number:   {0xD1, 0x9A, 0x66, 0xFF},
+		highlight.String:   {0x98, 0xC3, 0x79, 0xFF},
+		highlight.Type:     {0x61, 0xAF, 0xEF, 0xFF},
+		highlight.Variable: {0xE0, 0x6C, 0x75, 0xFF},
+	},
+}
+
+// Themes lists every theme, default first.
+var Themes = []*Theme{Light, Dark}
+
+// LookupTheme returns the theme with the given name, or nil if there isn't
+// one.
+func LookupTheme(name string) *Theme {
+	for _, t := range Themes {
+		if t.Name == name {
+			return t
+		}
+	}
+	return nil
+}
@@ -0,0 +1,386 @@
+// Package diff compares two texts line by line, using Myers' O(ND) algorithm,
+// and works out which words changed within each pair of changed lines. The
+// result can be shown as a unified diff or side by side.
+package diff
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// An Op says whether a line is in both texts, or only in the old or new one.
+type Op int
+
+const (
+	Equal Op = iota
+	Delete
+	Insert
+)
+
+// String returns the name of the op, which the templates use as a CSS class.
+func (op Op) String() string {
+	switch op {
+	case Delete:
+		return "delete"
+	case Insert:
+		return "insert"
+	default:
+		return "equal"
+	}
+}
+
+// A Segment is a piece of a line. Changed is set on the words of a deleted or
+// inserted line which differ from the line it replaced.
+type Segment struct {
+	Text    string
+	Changed bool
+}
+
+// A Line is one line of a diff. Old and New are its line numbers in the old
+// and new texts, counting from 1, with 0 on the side it isn't in.
+type Line struct {
+	Op       Op
+	Old, New int
+	Segments []Segment
+}
+
+// A Row is one row of a side by side diff. Either side is nil where a line was
+// only added or only removed.
+type Row struct {
+	Old, New *Line
+}
+
+// Options controls how lines are compared.
+type Options struct {
+	// IgnoreWhitespace treats lines as equal if they only differ in their
+	// whitespace, like diff -w.
+	IgnoreWhitespace bool
+}
+
+// Result holds the differences between two texts.
+type Result struct {
+	// Lines is the unified diff. Lines which are in both texts are shown as
+	// they are in the new one.
+	Lines []Line
+	// Rows is the same diff side by side.
+	Rows []Row
+	// Added and Deleted count the inserted and deleted lines.
+	Added, Deleted int
+}
+
+// Identical reports whether there were no differences.
+func (r *Result) Identical() bool {
+	return r.Added == 0 && r.Deleted == 0
+}
+
+// maxEdits limits the work done comparing two sequences. The memory Myers'
+// algorithm needs grows with the square of the number of edits, so beyond
+// this the sequences are treated as having nothing in common.
+const maxEdits = 2000
+
+// maxWords is the longest line, in words, which is compared word by word.
+// Longer changed lines are highlighted as a whole.
+const maxWords = 500
+
+// Compare returns the differences between the texts a and b.
+func Compare(a, b string, opts Options) *Result {
+	oldLines, newLines := splitLines(a), splitLines(b)
+	key := func(s string) string { return s }
+	if opts.IgnoreWhitespace {
+		key = stripSpace
+	}
+	oldKeys, newKeys := make([]string, len(oldLines)), make([]string, len(newLines))
+	for i, l := range oldLines {
+		oldKeys[i] = key(l)
+	}
+	for i, l := range newLines {
+		newKeys[i] = key(l)
+	}
+
+	res := &Result{}
+	edits := script(oldKeys, newKeys)
+	for i := 0; i < len(edits); {
+		if edits[i].op == Equal {
+			e := edits[i]
+			oldLine := Line{Op: Equal, Old: e.a + 1, New: e.b + 1, Segments: whole(oldLines[e.a], false)}
+			newLine := Line{Op: Equal, Old: e.a + 1, New: e.b + 1, Segments: whole(newLines[e.b], false)}
+			res.Lines = append(res.Lines, newLine)
+			res.Rows = append(res.Rows, Row{Old: &oldLine, New: &newLine})
+			i++
+			continue
+		}
+		// Gather a block of changes, and pair up the deleted and inserted
+		// lines in order so that the words which changed can be highlighted.
+		var deleted, inserted []Line
+		for ; i < len(edits) && edits[i].op != Equal; i++ {
+			e := edits[i]
+			if e.op == Delete {
+				deleted = append(deleted, Line{Op: Delete, Old: e.a + 1})
+			} else {
+				inserted = append(inserted, Line{Op: Insert, New: e.b + 1})
+			}
+		}
+		for j := range deleted {
+			old := oldLines[deleted[j].Old-1]
+			if j < len(inserted) {
+				deleted[j].Segments, inserted[j].Segments = words(old, newLines[inserted[j].New-1], opts)
+			} else {
+				deleted[j].Segments = whole(old, true)
+			}
+		}
+		for j := len(deleted); j < len(inserted); j++ {
+			inserted[j].Segments = whole(newLines[inserted[j].New-1], true)
+		}
+		res.Lines = append(res.Lines, deleted...)
+		res.Lines = append(res.Lines, inserted...)
+		for j := 0; j < max(len(deleted), len(inserted)); j++ {
+			var row Row
+			if j < len(deleted) {
+				row.Old = &deleted[j]
+			}
+			if j < len(inserted) {
+				row.New = &inserted[j]
+			}
+			res.Rows = append(res.Rows, row)
+		}
+		res.Deleted += len(deleted)
+		res.Added += len(inserted)
+	}
+	return res
+}
+
+// splitLines splits text into lines, without their line endings. A final line
+// ending doesn't start another line, and an empty text has no lines.
+func splitLines(text string) []string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.TrimSuffix(text, "\n")
+	if text == "" {
+		return nil
+	}
+	return strings.Split(text, "\n")
+}
+
+// stripSpace removes all whitespace from s.
+func stripSpace(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// whole returns a line as a single segment.
+func whole(s string, changed bool) []Segment {
+	if s == "" {
+		return nil
+	}
+	return []Segment{{Text: s, Changed: changed}}
+}
+
+// words compares a deleted line with the line inserted in its place, word by
+// word, and returns the segments of each with the words that differ marked as
+// changed. Whitespace is never marked when it is being ignored.
+func words(a, b string, opts Options) ([]Segment, []Segment) {
+	aTokens, bTokens := tokenize(a), tokenize(b)
+	if len(aTokens) > maxWords || len(bTokens) > maxWords {
+		return whole(a, true), whole(b, true)
+	}
+	// Compare the tokens that count, remembering where each one came from.
+	counted := func(tokens []string) (keys []string, index []int) {
+		for i, t := range tokens {
+			if opts.IgnoreWhitespace && stripSpace(t) == "" {
+				continue
+			}
+			keys = append(keys, t)
+			index = append(index, i)
+		}
+		return keys, index
+	}
+	aKeys, aIndex := counted(aTokens)
+	bKeys, bIndex := counted(bTokens)
+	aChanged, bChanged := make([]bool, len(aTokens)), make([]bool, len(bTokens))
+	for _, e := range script(aKeys, bKeys) {
+		switch e.op {
+		case Delete:
+			aChanged[aIndex[e.a]] = true
+		case Insert:
+			bChanged[bIndex[e.b]] = true
+		}
+	}
+	return segments(aTokens, aChanged), segments(bTokens, bChanged)
+}
+
+// segments joins runs of tokens which are all changed, or all unchanged.
+func segments(tokens []string, changed []bool) []Segment {
+	var segs []Segment
+	for i, t := range tokens {
+		if n := len(segs); n > 0 && segs[n-1].Changed == changed[i] {
+			segs[n-1].Text += t
+			continue
+		}
+		segs = append(segs, Segment{Text: t, Changed: changed[i]})
+	}
+	return segs
+}
+
+// tokenize splits a line into words, runs of whitespace and single punctuation
+// characters, so that a change is highlighted a word at a time rather than a
+// character at a time.
+func tokenize(s string) []string {
+	var tokens []string
+	for len(s) > 0 {
+		r, size := utf8.DecodeRuneInString(s)
+		n := size
+		switch {
+		case isWord(r):
+			for n < len(s) {
+				r, size := utf8.DecodeRuneInString(s[n:])
+				if !isWord(r) {
+					break
+				}
+				n += size
+			}
+		case unicode.IsSpace(r):
+			for n < len(s) {
+				r, size := utf8.DecodeRuneInString(s[n:])
+				if !unicode.IsSpace(r) {
+					break
+				}
+				n += size
+			}
+		}
+		tokens = append(tokens, s[:n])
+		s = s[n:]
+	}
+	return tokens
+}
+
+func isWord(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// An edit is one step of an edit script. a and b are the indexes of the
+// element in the old and new sequences; only a is set for a Delete, and only
+// b for an Insert.
+type edit struct {
+	op   Op
+	a, b int
+}
+
+// script returns the shortest edit script turning a into b, found with Myers'
+// algorithm. Common leading and trailing elements are matched up first, since
+// snippets being compared usually differ in only a few places.
+func script[T comparable](a, b []T) []edit {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var edits []edit
+	for i := 0; i < prefix; i++ {
+		edits = append(edits, edit{Equal, i, i})
+	}
+	for _, e := range myers(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]) {
+		e.a += prefix
+		e.b += prefix
+		edits = append(edits, e)
+	}
+	for i := suffix; i > 0; i-- {
+		edits = append(edits, edit{Equal, len(a) - i, len(b) - i})
+	}
+	return edits
+}
+
+// myers implements the greedy forward search from "An O(ND) Difference
+// Algorithm and Its Variations" (Myers, 1986), keeping the furthest reaching
+// path on each diagonal after every round so that the path can be traced back.
+func myers[T comparable](a, b []T) []edit {
+	n, m := len(a), len(b)
+	replaceAll := func() []edit {
+		edits := make([]edit, 0, n+m)
+		for i := range a {
+			edits = append(edits, edit{op: Delete, a: i})
+		}
+		for j := range b {
+			edits = append(edits, edit{op: Insert, b: j})
+		}
+		return edits
+	}
+	if n == 0 || m == 0 {
+		return replaceAll()
+	}
+
+	// v[k+offset] is the furthest x reached on diagonal k = x - y. trace[d]
+	// keeps the diagonals -d to d of v as they were after round d.
+	offset := n + m
+	v := make([]int, 2*offset+2)
+	var trace [][]int
+	for d := 0; d <= min(n+m, maxEdits); d++ {
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[k-1+offset] < v[k+1+offset]) {
+				x = v[k+1+offset] // Down from diagonal k+1: an insertion.
+			} else {
+				x = v[k-1+offset] + 1 // Right from diagonal k-1: a deletion.
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x, y = x+1, y+1
+			}
+			v[k+offset] = x
+			if x >= n && y >= m {
+				trace = append(trace, append([]int(nil), v[offset-d:offset+d+1]...))
+				return backtrack(trace, n, m)
+			}
+		}
+		trace = append(trace, append([]int(nil), v[offset-d:offset+d+1]...))
+	}
+	return replaceAll()
+}
+
+// backtrack follows the path found by myers back from (n, m) to (0, 0), and
+// returns the edits along it in order.
+func backtrack(trace [][]int, n, m int) []edit {
+	var edits []edit
+	x, y := n, m
+	for d := len(trace) - 1; d > 0; d-- {
+		// prev holds diagonals -(d-1) to d-1 as they were after round d-1.
+		prev := trace[d-1]
+		at := func(k int) int { return prev[k+d-1] }
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := at(prevK)
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x, y = x-1, y-1
+			edits = append(edits, edit{Equal, x, y})
+		}
+		if x == prevX {
+			y--
+			edits = append(edits, edit{op: Insert, b: y})
+		} else {
+			x--
+			edits = append(edits, edit{op: Delete, a: x})
+		}
+	}
+	// Whatever is left is the snake at the start of the path.
+	for x > 0 && y > 0 {
+		x, y = x-1, y-1
+		edits = append(edits, edit{Equal, x, y})
+	}
+	for i, j := 0, len(edits)-1; i < j; i, j = i+1, j-1 {
+		edits[i], edits[j] = edits[j], edits[i]
+	}
+	return edits
+}
@@ -0,0 +1,188 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cipto-hd/snippetbox/internal/assert"
+)
+
+// render writes a unified diff in the usual text form, with changed words in
+// square brackets, so that results are easy to compare.
+func render(lines []Line) string {
+	var b strings.Builder
+	for _, l := range lines {
+		b.WriteString(map[Op]string{Equal: " ", Delete: "-", Insert: "+"}[l.Op])
+		for _, s := range l.Segments {
+			if s.Changed {
+				b.WriteString("[" + s.Text + "]")
+			} else {
+				b.WriteString(s.Text)
+			}
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		name        string
+		a, b        string
+		opts        Options
+		want        string
+		wantAdded   int
+		wantDeleted int
+	}{
+		{
+			name: "Identical",
+			a:    "one\ntwo\n",
+			b:    "one\ntwo",
+			want: " one\n two\n",
+		},
+		{
+			name:      "Added line",
+			a:         "one\nthree",
+			b:         "one\ntwo\nthree",
+			want:      " one\n+[two]\n three\n",
+			wantAdded: 1,
+		},
+		{
+			name:        "Deleted line",
+			a:           "one\ntwo\nthree",
+			b:           "one\nthree",
+			want:        " one\n-[two]\n three\n",
+			wantDeleted: 1,
+		},
+		{
+			name:        "Changed word",
+			a:           "x := 1\nreturn x",
+			b:           "y := 1\nreturn x",
+			want:        "-[x] := 1\n+[y] := 1\n return x\n",
+			wantAdded:   1,
+			wantDeleted: 1,
+		},
+		{
+			name:        "Whitespace counts",
+			a:           "if x {\n\treturn\n}",
+			b:           "if x {\n    return\n}",
+			want:        " if x {\n-[\t]return\n+[    ]return\n }\n",
+			wantAdded:   1,
+			wantDeleted: 1,
+		},
+		{
+			name: "Whitespace ignored",
+			a:    "if x {\n\treturn\n}",
+			b:    "if x {\n    return\n}",
+			opts: Options{IgnoreWhitespace: true},
+			want: " if x {\n     return\n }\n",
+		},
+		{
+			name:        "Whitespace ignored within changed line",
+			a:           "a  =  1",
+			b:           "a = 2",
+			opts:        Options{IgnoreWhitespace: true},
+			want:        "-a  =  [1]\n+a = [2]\n",
+			wantAdded:   1,
+			wantDeleted: 1,
+		},
+		{
+			name:      "Empty old text",
+			a:         "",
+			b:         "one",
+			want:      "+[one]\n",
+			wantAdded: 1,
+		},
+		{
+			name:        "Replaced everything",
+			a:           "a\nb",
+			b:           "c",
+			want:        "-[a]\n-[b]\n+[c]\n",
+			wantAdded:   1,
+			wantDeleted: 2,
+		},
+		{
+			name:        "Windows line endings",
+			a:           "one\r\ntwo\r\n",
+			b:           "one\ntwo\nthree\n",
+			want:        " one\n two\n+[three]\n",
+			wantAdded:   1,
+			wantDeleted: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := Compare(tt.a, tt.b, tt.opts)
+			assert.Equal(t, render(res.Lines), tt.want)
+			assert.Equal(t, res.Added, tt.wantAdded)
+			assert.Equal(t, res.Deleted, tt.wantDeleted)
+			assert.Equal(t, res.Identical(), tt.wantAdded == 0 && tt.wantDeleted == 0)
+		})
+	}
+}
+
+func TestCompareRows(t *testing.T) {
+	res := Compare("one\ntwo\nthree\nfour", "one\n2\nthree", Options{})
+	assert.Equal(t, len(res.Rows), 4)
+	assert.Equal(t, res.Rows[0].Old.Op, Equal)
+	assert.Equal(t, res.Rows[1].Old.Old, 2)
+	assert.Equal(t, res.Rows[1].New.New, 2)
+	assert.Equal(t, res.Rows[2].New.New, 3)
+	assert.Equal(t, res.Rows[3].Old.Op, Delete)
+	assert.Equal(t, res.Rows[3].New == nil, true)
+}
+
+// TestScript checks that the edit scripts are as short as possible, by
+// comparing their length with the length of the longest common subsequence.
+func TestScript(t *testing.T) {
+	tests := []struct{ a, b string }{
+		{"ABCABBA", "CBABAC"},
+		{"abcdef", "azced"},
+		{"", "abc"},
+		{"abc", ""},
+		{"kitten", "sitting"},
+		{"aaaa", "aa"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.a+"/"+tt.b, func(t *testing.T) {
+			a, b := []rune(tt.a), []rune(tt.b)
+			edits := script(a, b)
+			equal := 0
+			var gotA, gotB []rune
+			for _, e := range edits {
+				switch e.op {
+				case Equal:
+					equal++
+					assert.Equal(t, a[e.a], b[e.b])
+					gotA, gotB = append(gotA, a[e.a]), append(gotB, b[e.b])
+				case Delete:
+					gotA = append(gotA, a[e.a])
+				case Insert:
+					gotB = append(gotB, b[e.b])
+				}
+			}
+			// The script must cover both sequences, in order.
+			assert.Equal(t, string(gotA), tt.a)
+			assert.Equal(t, string(gotB), tt.b)
+			assert.Equal(t, equal, lcs(a, b))
+		})
+	}
+}
+
+// lcs returns the length of the longest common subsequence of a and b.
+func lcs(a, b []rune) int {
+	dp := make([][]int, len(a)+1)
+	for i := range dp {
+		dp[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				dp[i][j] = dp[i+1][j+1] + 1
+			} else {
+				dp[i][j] = max(dp[i+1][j], dp[i][j+1])
+			}
+		}
+	}
+	return dp[0][0]
+}
@@ -0,0 +1,394 @@
+// Package highlight provides simple server-side syntax highlighting for
+// snippets. Code is split into tokens with a small set of rules per language,
+// mostly regular expressions, and each token is wrapped in a <span> with a
+// short class name ("k" for keywords, "s" for strings and so on) so that all
+// of the styling lives in the stylesheet. No inline styles or scripts are
+// emitted, so the output is safe under a strict Content-Security-Policy.
+package highlight
+
+import (
+	"html/template"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// The CSS classes emitted for each kind of token.
+const (
+	Comment  = "c"
+	Keyword  = "k"
+	Number   = "n"
+	String   = "s"
+	Type     = "t"
+	Variable = "v"
+)
+
+// A rule matches one kind of token at the start of the remaining input.
+// match returns the length of the token, or 0 if the input doesn't start with
+// one. Rules with lineStart set are only tried at the start of a line, since
+// the remaining input always starts at offset 0 and so ^ can't tell.
+type rule struct {
+	class     string
+	match     func(rest string) int
+	lineStart bool
+}
+
+// A Language describes how to highlight one programming language.
+type Language struct {
+	// Name is the identifier stored with each snippet, like "go".
+	Name string
+	// Label is the human-readable name shown in the language picker.
+	Label string
+	// Extension is the usual file extension, without the leading dot.
+	Extension string
+	rules     []rule
+}
+
+// newRule compiles a rule, anchoring the pattern to the start of the input.
+func newRule(class, pattern string) rule {
+	rx := regexp.MustCompile(`\A(?:` + pattern + `)`)
+	return rule{class: class, match: func(rest string) int {
+		return len(rx.FindString(rest))
+	}}
+}
+
+// delimited returns a rule for tokens which run from open to the next close,
+// like block comments. An unterminated token runs to the end of the input, as
+// it would in an editor. This takes one scan of the input, where a lazy
+// regular expression would rescan the rest of the input from every opener
+// without a close, which is quadratic.
+func delimited(class, open, close string) rule {
+	return rule{class: class, match: func(rest string) int {
+		if !strings.HasPrefix(rest, open) {
+			return 0
+		}
+		i := strings.Index(rest[len(open):], close)
+		if i < 0 {
+			return len(rest)
+		}
+		return len(open) + i + len(close)
+	}}
+}
+
+// quoted returns a rule for strings in q quotes, with backslash escapes, which
+// end at the end of the line. An unterminated string runs to the end of the
+// line, so that it's scanned once rather than from every escaped quote in it.
+func quoted(q byte) rule {
+	return rule{class: String, match: func(rest string) int {
+		if rest == "" || rest[0] != q {
+			return 0
+		}
+		for i := 1; i < len(rest); i++ {
+			switch rest[i] {
+			case '\\':
+				if i+1 < len(rest) && rest[i+1] != '\n' {
+					i++
+				}
+			case q:
+				return i + 1
+			case '\n':
+				return i
+			}
+		}
+		return len(rest)
+	}}
+}
+
+// fencedBlock is the rule for Markdown code blocks, which run from a line
+// starting with ``` or ~~~ to a line of just ``` or ~~~. As in CommonMark, an
+// unclosed block runs to the end of the input.
+var fencedBlock = rule{class: String, lineStart: true, match: func(rest string) int {
+	if !strings.HasPrefix(rest, "```") && !strings.HasPrefix(rest, "~~~") {
+		return 0
+	}
+	i := strings.IndexByte(rest, '\n')
+	if i < 0 {
+		return 0
+	}
+	for i < len(rest) {
+		start := i + 1
+		end := strings.IndexByte(rest[start:], '\n')
+		if end < 0 {
+			end = len(rest)
+		} else {
+			end += start
+		}
+		line := strings.TrimRight(rest[start:end], " \t")
+		if line == "```" || line == "~~~" {
+			return end
+		}
+		i = end
+	}
+	return len(rest)
+}}
+
+// atLineStart returns r, restricted to matching at the start of a line.
+func atLineStart(r rule) rule {
+	r.lineStart = true
+	return r
+}
+
+// words returns a pattern matching any of the given whole words.
+func words(ws ...string) string {
+	return `\b(?:` + strings.Join(ws, "|") + `)\b`
+}
+
+// Patterns shared by several languages.
+const (
+	cLineComment   = `//[^\n]*`
+	hashComment    = `#[^\n]*`
+	backtickQuoted = "`[^`]*`"
+	number         = `\b(?:0[xX][0-9a-fA-F_]+|\d[\d_]*(?:\.\d+)?(?:[eE][+-]?\d+)?)\b`
+)
+
+// Text is the default language, which applies no highlighting at all.
+var Text = &Language{Name: "text", Label: "Plain text", Extension: "txt"}
+
+// Languages lists every supported language, in the order they are offered in
+// the language picker.
+var Languages = []*Language{
+	Text,
+	{
+		Name: "go", Label: "Go", Extension: "go",
+		rules: []rule{
+			newRule(Comment, cLineComment),
+			delimited(Comment, "/*", "*/"),
+			quoted('"'),
+			newRule(String, backtickQuoted),
+			quoted('\''),
+			newRule(Number, number),
+			newRule(Keyword, words("break", "case", "chan", "const", "continue", "default", "defer", "else",
+				"fallthrough", "for", "func", "go", "goto", "if", "import", "interface", "map", "package",
+				"range", "return", "select", "struct", "switch", "type", "var", "nil", "true", "false", "iota")),
+			newRule(Type, words("any", "bool", "byte", "complex64", "complex128", "error", "float32", "float64",
+				"int", "int8", "int16", "int32", "int64", "rune", "string", "uint", "uint8", "uint16", "uint32",
+				"uint64", "uintptr")),
+		},
+	},
+	{
+		Name: "python", Label: "Python", Extension: "py",
+		rules: []rule{
+			newRule(Comment, hashComment),
+			delimited(String, `"""`, `"""`),
+			delimited(String, `'''`, `'''`),
+			quoted('"'),
+			quoted('\''),
+			newRule(Number, number),
+			newRule(Keyword, words("and", "as", "assert", "async", "await", "break", "class", "continue", "def",
+				"del", "elif", "else", "except", "finally", "for", "from", "global", "if", "import", "in", "is",
+				"lambda", "nonlocal", "not", "or", "pass", "raise", "return", "try", "while", "with", "yield",
+				"None", "True", "False")),
+			newRule(Type, words("bool", "bytes", "dict", "float", "int", "list", "object", "set", "str", "tuple")),
+		},
+	},
+	{
+		Name: "javascript", Label: "JavaScript", Extension: "js",
+		rules: []rule{
+			newRule(Comment, cLineComment),
+			delimited(Comment, "/*", "*/"),
+			quoted('"'),
+			quoted('\''),
+			newRule(String, backtickQuoted),
+			newRule(Number, number),
+			newRule(Keyword, words("async", "await", "break", "case", "catch", "class", "const", "continue",
+				"debugger", "default", "delete", "do", "else", "export", "extends", "finally", "for", "function",
+				"if", "import", "in", "instanceof", "let", "new", "of", "return", "super", "switch", "this",
+				"throw", "try", "typeof", "var", "void", "while", "with", "yield", "null", "undefined", "true",
+				"false")),
+		},
+	},
+	{
+		Name: "sql", Label: "SQL", Extension: "sql",
+		rules: []rule{
+			newRule(Comment, `--[^\n]*`),
+			delimited(Comment, "/*", "*/"),
+			quoted('\''),
+			newRule(String, backtickQuoted),
+			newRule(Number, number),
+			newRule(Keyword, `(?i)`+words("add", "all", "alter", "and", "as", "asc", "by", "case", "create",
+				"delete", "desc", "distinct", "drop", "else", "end", "exists", "from", "group", "having", "in",
+				"index", "inner", "insert", "into", "is", "join", "key", "left", "like", "limit", "not", "null",
+				"offset", "on", "or", "order", "outer", "primary", "right", "select", "set", "table", "then",
+				"union", "update", "values", "when", "where")),
+			newRule(Type, `(?i)`+words("bigint", "char", "date", "datetime", "decimal", "int", "integer",
+				"text", "timestamp", "varchar")),
+		},
+	},
+	{
+		Name: "shell", Label: "Shell", Extension: "sh",
+		rules: []rule{
+			newRule(Comment, hashComment),
+			quoted('"'),
+			newRule(String, `'[^']*'`),
+			// The braces can't contain another brace, so that a line of
+			// unclosed ones isn't rescanned from each of them.
+			newRule(Variable, `\$(?:\{[^{}\n]*\}|[A-Za-z_]\w*|[0-9@#?*$!-])`),
+			newRule(Number, number),
+			newRule(Keyword, words("case", "do", "done", "elif", "else", "esac", "export", "fi", "for",
+				"function", "if", "in", "local", "return", "then", "until", "while")),
+		},
+	},
+	{
+		Name: "yaml", Label: "YAML", Extension: "yaml",
+		rules: []rule{
+			newRule(Comment, hashComment),
+			quoted('"'),
+			newRule(String, `'[^'\n]*'`),
+			newRule(Number, number),
+			newRule(Keyword, words("true", "false", "null", "yes", "no", "on", "off")),
+		},
+	},
+	{
+		Name: "json", Label: "JSON", Extension: "json",
+		rules: []rule{
+			quoted('"'),
+			newRule(Number, `-?`+number),
+			newRule(Keyword, words("true", "false", "null")),
+		},
+	},
+	{
+		// Markdown snippets are shown rendered by default, and highlighted
+		// like this when viewing the source.
+		Name: "markdown", Label: "Markdown", Extension: "md",
+		rules: []rule{
+			atLineStart(newRule(Keyword, `#{1,6}[ \t][^\n]*`)),
+			fencedBlock,
+			newRule(String, backtickQuoted),
+			// Likewise, links can't contain the start of another link.
+			newRule(Variable, `!?\[[^\[\]\n]*\]\([^)\[\n]*\)`),
+			newRule(Keyword, `\*\*[^*\n]+\*\*|__[^_\n]+__`),
+		},
+	},
+}
+
+// identifierRX matches a run of word characters. Words which aren't matched by
+// any rule are consumed whole, so that a keyword rule can never match part of
+// a longer identifier (like the "for" in "format").
+var identifierRX = regexp.MustCompile(`\A\w+`)
+
+// Lookup returns the language with the given name, or nil if there isn't one.
+func Lookup(name string) *Language {
+	for _, lang := range Languages {
+		if lang.Name == name {
+			return lang
+		}
+	}
+	return nil
+}
+
+// Names returns the names of all the supported languages.
+func Names() []string {
+	names := make([]string, len(Languages))
+	for i, lang := range Languages {
+		names[i] = lang.Name
+	}
+	return names
+}
+
+// A token is a run of source text and the class it should be styled with.
+// Plain text has an empty class.
+type token struct {
+	class string
+	text  string
+}
+
+// tokenize splits code into tokens using the language's rules.
+func (lang *Language) tokenize(code string) []token {
+	var tokens []token
+	// plain accumulates unhighlighted text, so that runs of it are emitted as
+	// a single token.
+	var plain strings.Builder
+	flush := func() {
+		if plain.Len() > 0 {
+			tokens = append(tokens, token{text: plain.String()})
+			plain.Reset()
+		}
+	}
+
+	rest := code
+next:
+	for len(rest) > 0 {
+		lineStart := len(rest) == len(code) || code[len(code)-len(rest)-1] == '\n'
+		for _, r := range lang.rules {
+			if r.lineStart && !lineStart {
+				continue
+			}
+			if n := r.match(rest); n > 0 {
+				flush()
+				tokens = append(tokens, token{class: r.class, text: rest[:n]})
+				rest = rest[n:]
+				continue next
+			}
+		}
+		m := identifierRX.FindString(rest)
+		if m == "" {
+			_, size := utf8.DecodeRuneInString(rest)
+			m = rest[:size]
+		}
+		plain.WriteString(m)
+		rest = rest[len(m):]
+	}
+	flush()
+	return tokens
+}
+
+// A Span is a run of code within a single line, and the class it should be
+// styled with, which is one of the constants above or empty for plain text.
+type Span struct {
+	Class string
+	Text  string
+}
+
+// Spans highlights code in the named language and returns the spans of each
+// line of code, without the trailing newlines. Tokens which span several
+// lines, like block comments, are split so that each line stands alone.
+// Unknown languages are treated as plain text.
+func Spans(language, code string) [][]Span {
+	lang := Lookup(language)
+	if lang == nil {
+		lang = Text
+	}
+	code = strings.ReplaceAll(code, "\r\n", "\n")
+
+	var lines [][]Span
+	var line []Span
+	for _, tok := range lang.tokenize(code) {
+		for i, part := range strings.Split(tok.text, "\n") {
+			if i > 0 {
+				lines = append(lines, line)
+				line = nil
+			}
+			if part == "" {
+				continue
+			}
+			line = append(line, Span{Class: tok.class, Text: part})
+		}
+	}
+	// Don't add an empty final line for code which ends with a newline.
+	if len(line) > 0 || len(lines) == 0 {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// Lines highlights code like Spans, and returns one HTML fragment per line,
+// with each highlighted span wrapped in a <span> of its class.
+func Lines(language, code string) []template.HTML {
+	spans := Spans(language, code)
+	lines := make([]template.HTML, len(spans))
+	var line strings.Builder
+	for i, spans := range spans {
+		line.Reset()
+		for _, span := range spans {
+			if span.Class == "" {
+				line.WriteString(template.HTMLEscapeString(span.Text))
+			} else {
+				line.WriteString(`<span class="` + span.Class + `">`)
+				line.WriteString(template.HTMLEscapeString(span.Text))
+				line.WriteString(`</span>`)
+			}
+		}
+		lines[i] = template.HTML(line.String())
+	}
+	return lines
+}
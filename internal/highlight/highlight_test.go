@@ -0,0 +1,149 @@
+package highlight
+
+import (
+	"html/template"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cipto-hd/snippetbox/internal/assert"
+)
+
+func TestLines(t *testing.T) {
+	tests := []struct {
+		name     string
+		language string
+		code     string
+		want     []template.HTML
+	}{
+		{
+			name:     "Plain text",
+			language: "text",
+			code:     "for <b>\nnext",
+			want:     []template.HTML{"for &lt;b&gt;", "next"},
+		},
+		{
+			name:     "Unknown language",
+			language: "cobol",
+			code:     "func",
+			want:     []template.HTML{"func"},
+		},
+		{
+			name:     "Go keywords and strings",
+			language: "go",
+			code:     `return "a<b"`,
+			want:     []template.HTML{`<span class="k">return</span> <span class="s">&#34;a&lt;b&#34;</span>`},
+		},
+		{
+			name:     "Keyword inside identifier",
+			language: "go",
+			code:     "format := 10",
+			want:     []template.HTML{`format := <span class="n">10</span>`},
+		},
+		{
+			name:     "Multi-line comment",
+			language: "go",
+			code:     "/* one\ntwo */ x",
+			want:     []template.HTML{`<span class="c">/* one</span>`, `<span class="c">two */</span> x`},
+		},
+		{
+			name:     "Unterminated comment",
+			language: "go",
+			code:     "x /* one\ntwo",
+			want:     []template.HTML{`x <span class="c">/* one</span>`, `<span class="c">two</span>`},
+		},
+		{
+			name:     "Unterminated string",
+			language: "go",
+			code:     `x := "a\"b` + "\ny",
+			want:     []template.HTML{`x := <span class="s">&#34;a\&#34;b</span>`, `y`},
+		},
+		{
+			name:     "Unclosed code block",
+			language: "markdown",
+			code:     "```go\nx",
+			want:     []template.HTML{"<span class=\"s\">```go</span>", `<span class="s">x</span>`},
+		},
+		{
+			name:     "Mid-line hash",
+			language: "markdown",
+			code:     "a # b\n# c",
+			want:     []template.HTML{`a # b`, `<span class="k"># c</span>`},
+		},
+		{
+			name:     "Mid-line backticks",
+			language: "markdown",
+			code:     "x ```go\ny",
+			want:     []template.HTML{"x <span class=\"s\">``</span>`go", `y`},
+		},
+		{
+			name:     "Trailing newline",
+			language: "sql",
+			code:     "SELECT 1;\n",
+			want:     []template.HTML{`<span class="k">SELECT</span> <span class="n">1</span>;`},
+		},
+		{
+			name:     "Empty lines kept",
+			language: "shell",
+			code:     "echo $HOME\r\n\r\n# done",
+			want:     []template.HTML{`echo <span class="v">$HOME</span>`, ``, `<span class="c"># done</span>`},
+		},
+		{
+			name:     "Markdown",
+			language: "markdown",
+			code:     "# Title\nSee **[docs](/a)**\n```\nx\n```",
+			want: []template.HTML{`<span class="k"># Title</span>`, `See <span class="k">**[docs](/a)**</span>`,
+				"<span class=\"s\">```</span>", `<span class="s">x</span>`, "<span class=\"s\">```</span>"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines := Lines(tt.language, tt.code)
+			assert.Equal(t, len(lines), len(tt.want))
+			for i := range tt.want {
+				if i < len(lines) {
+					assert.Equal(t, lines[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestSpans(t *testing.T) {
+	got := Spans("go", "x := 1 /* a\nb */\n")
+	want := [][]Span{
+		{{Text: "x := "}, {Class: Number, Text: "1"}, {Text: " "}, {Class: Comment, Text: "/* a"}},
+		{{Class: Comment, Text: "b */"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
+
+// TestLinesLinear checks that highlighting takes time in proportion to the
+// size of the code, even for code full of tokens which are never closed.
+// Highlighting any of these took minutes when it was quadratic.
+func TestLinesLinear(t *testing.T) {
+	tests := []struct {
+		language string
+		unit     string
+	}{
+		{"go", "/* "},
+		{"go", `"\`},
+		{"python", `""" `},
+		{"markdown", "```a\n"},
+		{"markdown", "![a](b"},
+		{"shell", "${a "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.language+" "+tt.unit, func(t *testing.T) {
+			code := strings.Repeat(tt.unit, 256*1024/len(tt.unit))
+			start := time.Now()
+			Lines(tt.language, code)
+			if d := time.Since(start); d > 5*time.Second {
+				t.Errorf("took %v", d)
+			}
+		})
+	}
+}
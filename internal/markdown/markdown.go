@@ -0,0 +1,121 @@
+// Package markdown renders markdown snippets to HTML. GitHub Flavored
+// Markdown tables, strikethrough, autolinks and task lists are supported, and
+// fenced code blocks are highlighted with the highlight package.
+//
+// Raw HTML in the source is never passed through, and the rendered HTML is
+// cleaned with a strict allowlist of elements and attributes before it's
+// used: no scripts, event handlers, inline styles or frames, and images may
+// only be loaded from this site.
+package markdown
+
+import (
+	"bytes"
+	"html/template"
+	"regexp"
+	"strings"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/util"
+
+	"github.com/cipto-hd/snippetbox/internal/highlight"
+)
+
+var md = goldmark.New(
+	goldmark.WithExtensions(
+		extension.Strikethrough,
+		extension.Linkify,
+		extension.TaskList,
+		// Align table cells with the align attribute, since style attributes
+		// are blocked by the Content-Security-Policy.
+		extension.NewTable(extension.WithTableCellAlignMethod(extension.TableCellAlignAttribute)),
+	),
+	goldmark.WithRendererOptions(
+		renderer.WithNodeRenderers(util.Prioritized(codeBlockRenderer{}, 100)),
+	),
+)
+
+// sameOriginRX matches URLs which can only refer to this site: absolute
+// paths, but not protocol-relative ones like //example.com, and relative paths
+// without a scheme.
+var sameOriginRX = regexp.MustCompile(`^(?:/(?:[^/\\][^:]*)?|[^/\\:][^:]*)$`)
+
+var policy = newPolicy()
+
+// newPolicy returns the allowlist which rendered HTML is cleaned with.
+func newPolicy() *bluemonday.Policy {
+	p := bluemonday.NewPolicy()
+	p.AllowElements("p", "br", "hr", "h1", "h2", "h3", "h4", "h5", "h6", "blockquote",
+		"ul", "ol", "li", "pre", "code", "em", "strong", "del",
+		"table", "thead", "tbody", "tr", "th", "td")
+
+	p.AllowStandardURLs()
+	p.AllowAttrs("href", "title").OnElements("a")
+	p.RequireNoFollowOnLinks(true)
+	p.RequireNoReferrerOnLinks(true)
+	p.AllowAttrs("src").Matching(sameOriginRX).OnElements("img")
+	p.AllowAttrs("alt", "title").OnElements("img")
+
+	p.AllowAttrs("start").Matching(bluemonday.Integer).OnElements("ol")
+	p.AllowAttrs("align").Matching(regexp.MustCompile(`^(?:left|center|right)$`)).OnElements("th", "td")
+	// Task list items are rendered as disabled checkboxes.
+	p.AllowAttrs("type").Matching(regexp.MustCompile(`^checkbox$`)).OnElements("input")
+	p.AllowAttrs("checked", "disabled").Matching(regexp.MustCompile(`^(?:|checked|disabled)$`)).OnElements("input")
+	// Highlighted code blocks use the same classes as snippets.
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^code$`)).OnElements("pre")
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^[cknstv]$`)).OnElements("span")
+	return p
+}
+
+// Render converts markdown source to sanitized HTML. If the source can't be
+// rendered it's shown escaped, as plain text.
+func Render(source string) template.HTML {
+	var buf bytes.Buffer
+	err := md.Convert([]byte(source), &buf)
+	if err != nil {
+		return template.HTML("<pre>" + template.HTMLEscapeString(source) + "</pre>")
+	}
+	return template.HTML(policy.SanitizeBytes(buf.Bytes()))
+}
+
+// codeBlockRenderer renders fenced code blocks with syntax highlighting, when
+// the language named after the opening fence is one we know. Languages can be
+// given by name or by file extension, like "go" or "py".
+type codeBlockRenderer struct{}
+
+func (codeBlockRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(ast.KindFencedCodeBlock, renderCodeBlock)
+}
+
+func renderCodeBlock(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	n := node.(*ast.FencedCodeBlock)
+	var code strings.Builder
+	for i := 0; i < n.Lines().Len(); i++ {
+		line := n.Lines().At(i)
+		code.Write(line.Value(source))
+	}
+	language := highlight.Text.Name
+	if name := string(n.Language(source)); name != "" {
+		for _, lang := range highlight.Languages {
+			if lang.Name == name || lang.Extension == name {
+				language = lang.Name
+				break
+			}
+		}
+	}
+	w.WriteString(`<pre class="code"><code>`)
+	for i, line := range highlight.Lines(language, code.String()) {
+		if i > 0 {
+			w.WriteString("\n")
+		}
+		w.WriteString(string(line))
+	}
+	w.WriteString("</code></pre>\n")
+	return ast.WalkSkipChildren, nil
+}
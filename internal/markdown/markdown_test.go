@@ -0,0 +1,87 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cipto-hd/snippetbox/internal/assert"
+)
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   []string
+		absent []string
+	}{
+		{
+			name:   "Heading and emphasis",
+			source: "# Title\n\nSome **bold** and ~~old~~ text",
+			want:   []string{"<h1>Title</h1>", "<strong>bold</strong>", "<del>old</del>"},
+		},
+		{
+			name:   "Table",
+			source: "| a | b |\n|:--|--:|\n| 1 | 2 |",
+			want:   []string{"<table>", `<th align="left">a</th>`, `<td align="right">2</td>`},
+		},
+		{
+			name:   "Task list",
+			source: "- [x] done\n- [ ] todo",
+			want:   []string{`<input checked="" disabled="" type="checkbox"> done`, `<input disabled="" type="checkbox"> todo`},
+		},
+		{
+			name:   "Fenced code",
+			source: "```go\nreturn \"<b>\"\n```",
+			want:   []string{`<pre class="code"><code><span class="k">return</span> <span class="s">&#34;&lt;b&gt;&#34;</span></code></pre>`},
+		},
+		{
+			name:   "Fenced code by extension",
+			source: "```py\nNone\n```",
+			want:   []string{`<span class="k">None</span>`},
+		},
+		{
+			name:   "Links",
+			source: "[home](/) and https://example.com",
+			want:   []string{`<a href="/" rel="nofollow noreferrer">home</a>`, `<a href="https://example.com" rel="nofollow noreferrer">`},
+		},
+		{
+			name:   "Local image",
+			source: "![logo](/static/img/logo.png)",
+			want:   []string{`<img src="/static/img/logo.png" alt="logo">`},
+		},
+		{
+			name:   "Raw HTML",
+			source: "<script>alert(1)</script>\n\n<b onclick=\"alert(1)\">hi</b>",
+			absent: []string{"<script", "alert", "onclick", "<b"},
+		},
+		{
+			name:   "Script link",
+			source: "[click](javascript:alert(1))",
+			want:   []string{"click"},
+			absent: []string{"javascript", "href"},
+		},
+		{
+			name:   "Remote image",
+			source: "![x](https://evil.example/x.png) ![y](//evil.example/y.png)",
+			absent: []string{"evil.example"},
+		},
+		{
+			name:   "Data image",
+			source: "![x](data:image/svg+xml;base64,PHN2Zz4=)",
+			absent: []string{"data:"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			html := string(Render(tt.source))
+			for _, s := range tt.want {
+				assert.StringContains(t, html, s)
+			}
+			for _, s := range tt.absent {
+				if strings.Contains(html, s) {
+					t.Errorf("got %q; expected it not to contain %q", html, s)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+)
+
+// View returns the snippet with the given slug for display to a viewer, in the
+// same way as GetBySlug(), and counts the view against a burn-after-reading
+// snippet's limit. Views by the snippet's owner aren't counted.
+//
+// The snippet row is locked while the view is counted, so concurrent requests
+// are serialized and only MaxViews of them can ever see the content. When the
+// last view is used up the content, files and revisions are destroyed, leaving
+// just enough of the row behind for later visitors to get ErrBurned instead of
+// ErrNoRecord. The snippet returned for that last view still holds the
+// content and files. Large content which nothing else shares is deleted by
+// the next PurgeContents().
+func (m *SnippetModel) View(slug string, viewerID int) (*Snippet, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets
+WHERE ` + unexpiredClause + ` AND deleted IS NULL AND ` + viewableClause + ` AND slug = ? FOR UPDATE`
+	s, err := scanSnippet(tx.QueryRow(stmt, viewerID, slug))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		}
+		return nil, err
+	}
+	if !s.Burned.IsZero() {
+		return nil, ErrBurned
+	}
+	// Load the files and content now, since burn() destroys them.
+	err = loadContents(tx, s)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.MaxViews > 0 && s.UserID != viewerID {
+		s.Views++
+		if s.Views < s.MaxViews {
+			_, err = tx.Exec(`UPDATE snippets SET views = views + 1 WHERE id = ?`, s.ID)
+		} else {
+			err = burn(tx, s.ID)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+	err = m.loadTags([]*Snippet{s})
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// burn destroys the content of a snippet which has used up its last view.
+func burn(tx *sql.Tx, id int) error {
+	stmt := `UPDATE snippets SET views = views + 1, content = '', content_hash = NULL, burned = UTC_TIMESTAMP() WHERE id = ?`
+	_, err := tx.Exec(stmt, id)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`DELETE FROM snippet_revisions WHERE snippet_id = ?`, id)
+	if err != nil {
+		return err
+	}
+	return setFiles(tx, id, nil)
+}
@@ -0,0 +1,148 @@
+package models
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// largeContent is the size in bytes above which the content of a snippet, file
+// or revision is moved out of its row and into the snippet_contents table.
+// The row keeps its content_hash, and the first largeContent bytes of the
+// content as an excerpt, so that listings still have something to work with
+// without loading the whole thing.
+//
+// Each distinct large content is stored only once, however many snippets,
+// files and revisions share it, and is compressed when that makes it smaller.
+// An uncompressed copy is kept in its text column too, for full-text search.
+// Content which nothing refers to any more is deleted by PurgeContents().
+const largeContent = 16 * 1024
+
+// errContentHash is returned when stored content doesn't match its hash.
+var errContentHash = errors.New("models: stored content doesn't match its hash")
+
+// storeContent prepares content for storing in a row, inside the transaction
+// tx. Small content is returned as it is, with a nil hash. Large content is
+// saved in snippet_contents, if it isn't there already, and an excerpt of it
+// is returned along with its hash.
+func storeContent(tx *sql.Tx, content string) (string, []byte, error) {
+	if len(content) <= largeContent {
+		return content, nil, nil
+	}
+	sum := sha256.Sum256([]byte(content))
+	data, compressed, err := compress(content)
+	if err != nil {
+		return "", nil, err
+	}
+	// Touching the used time of existing content stops PurgeContents() from
+	// deleting it while the row which refers to it is being inserted.
+	// The text column holds valid UTF-8 only, which is all that search needs.
+	stmt := `INSERT INTO snippet_contents (hash, size, compressed, data, text, used)
+VALUES(?, ?, ?, ?, ?, UTC_TIMESTAMP()) ON DUPLICATE KEY UPDATE used = UTC_TIMESTAMP()`
+	_, err = tx.Exec(stmt, sum[:], len(content), compressed, data, strings.ToValidUTF8(content, "\uFFFD"))
+	if err != nil {
+		return "", nil, err
+	}
+	return excerpt(content), sum[:], nil
+}
+
+// loadContent replaces *content, an excerpt, with the full content stored
+// under hash. It does nothing if hash is nil, since the content is then stored
+// in full in its row.
+func loadContent(q queryer, content *string, hash []byte) error {
+	if hash == nil {
+		return nil
+	}
+	var data []byte
+	var compressed bool
+	err := q.QueryRow(`SELECT compressed, data FROM snippet_contents WHERE hash = ?`, hash).Scan(&compressed, &data)
+	if err != nil {
+		return err
+	}
+	if compressed {
+		data, err = decompress(data)
+		if err != nil {
+			return err
+		}
+	}
+	sum := sha256.Sum256(data)
+	if !bytes.Equal(sum[:], hash) {
+		return errContentHash
+	}
+	*content = string(data)
+	return nil
+}
+
+// compress gzips content, and reports whether that made it any smaller. If
+// it didn't, the content is returned uncompressed.
+func compress(content string) ([]byte, bool, error) {
+	var buf bytes.Buffer
+	zw, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	if err != nil {
+		return nil, false, err
+	}
+	_, err = io.WriteString(zw, content)
+	if err != nil {
+		return nil, false, err
+	}
+	err = zw.Close()
+	if err != nil {
+		return nil, false, err
+	}
+	if buf.Len() >= len(content) {
+		return []byte(content), false, nil
+	}
+	return buf.Bytes(), true, nil
+}
+
+// decompress reverses compress.
+func decompress(data []byte) ([]byte, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return io.ReadAll(zr)
+}
+
+// excerpt returns the first largeContent bytes of content, cut back to the
+// end of a whole UTF-8 character so that it can be stored in a text column.
+func excerpt(content string) string {
+	if len(content) > largeContent {
+		content = content[:largeContent]
+		for len(content) > 0 {
+			r, size := utf8.DecodeLastRuneInString(content)
+			if r != utf8.RuneError || size != 1 {
+				break
+			}
+			content = content[:len(content)-1]
+		}
+	}
+	return strings.ToValidUTF8(content, "\uFFFD")
+}
+
+// PurgeContents deletes up to limit large contents which no snippet, file or
+// revision refers to any more, and returns how many it deleted. Content used
+// in the last hour is kept, in case a row which refers to it is still being
+// inserted.
+func (m *SnippetModel) PurgeContents(limit int) (int64, error) {
+	// MySQL doesn't allow LIMIT in an IN subquery, or a subquery on the table
+	// being deleted from, so the hashes are picked in a derived table.
+	stmt := `DELETE FROM snippet_contents WHERE hash IN (SELECT hash FROM (
+SELECT c.hash FROM snippet_contents c WHERE c.used < UTC_TIMESTAMP() - INTERVAL 1 HOUR
+AND NOT EXISTS (SELECT 1 FROM snippets WHERE content_hash = c.hash)
+AND NOT EXISTS (SELECT 1 FROM snippet_files WHERE content_hash = c.hash)
+AND NOT EXISTS (SELECT 1 FROM snippet_revisions WHERE content_hash = c.hash)
+AND NOT EXISTS (SELECT 1 FROM snippet_revision_files WHERE content_hash = c.hash)
+LIMIT ?) AS orphans) AND used < UTC_TIMESTAMP() - INTERVAL 1 HOUR`
+	result, err := m.DB.Exec(stmt, limit)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
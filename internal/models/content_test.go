@@ -0,0 +1,121 @@
+package models
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/cipto-hd/snippetbox/internal/assert"
+)
+
+func TestCompress(t *testing.T) {
+	tests := []struct {
+		name           string
+		content        string
+		wantCompressed bool
+	}{
+		{
+			name:           "Repetitive",
+			content:        strings.Repeat("GET /index.html 200\n", 1000),
+			wantCompressed: true,
+		},
+		{
+			name:           "Too short to shrink",
+			content:        "x",
+			wantCompressed: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, compressed, err := compress(tt.content)
+			assert.NilError(t, err)
+			assert.Equal(t, compressed, tt.wantCompressed)
+			if compressed {
+				data, err = decompress(data)
+				assert.NilError(t, err)
+			}
+			assert.Equal(t, string(data), tt.content)
+		})
+	}
+}
+
+func TestExcerpt(t *testing.T) {
+	// Cut through the middle of a three-byte character.
+	content := strings.Repeat("a", largeContent-1) + "€uro"
+	e := excerpt(content)
+	assert.Equal(t, e, strings.Repeat("a", largeContent-1))
+
+	e = excerpt(strings.Repeat("a", largeContent+10))
+	assert.Equal(t, len(e), largeContent)
+
+	e = excerpt("bad \xff byte")
+	assert.Equal(t, utf8.ValidString(e), true)
+}
+
+func TestSnippetModelLargeContent(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+	m := SnippetModel{db}
+	// Invalid UTF-8 must survive the round trip too.
+	log := strings.Repeat("ERROR something went wrong \xff\n", 2000)
+	newSnippet := func() *Snippet {
+		return &Snippet{
+			UserID:     1,
+			Title:      "A large log",
+			Visibility: VisibilityPublic,
+			Files: []*File{
+				{Name: "app.log", Language: "text", Content: log},
+				{Name: "copy.log", Language: "text", Content: log},
+			},
+		}
+	}
+	id1, err := m.Insert(newSnippet())
+	assert.NilError(t, err)
+	id2, err := m.Insert(newSnippet())
+	assert.NilError(t, err)
+
+	for _, id := range []int{id1, id2} {
+		s, err := m.Get(id, 0)
+		assert.NilError(t, err)
+		assert.Equal(t, s.Content, log)
+		assert.Equal(t, s.Files[0].Content, log)
+		assert.Equal(t, s.Files[1].Content, log)
+	}
+
+	// Both snippets and all their files share one compressed copy.
+	var count, size int
+	var compressed bool
+	err = db.QueryRow(`SELECT COUNT(*), MAX(size), MAX(compressed) FROM snippet_contents`).Scan(&count, &size, &compressed)
+	assert.NilError(t, err)
+	assert.Equal(t, count, 1)
+	assert.Equal(t, size, len(log))
+	assert.Equal(t, compressed, true)
+
+	// Listings only have an excerpt.
+	snippets, err := m.ByUser(1)
+	assert.NilError(t, err)
+	assert.Equal(t, snippets[0].Content, excerpt(log))
+
+	// The old content is kept for the revision.
+	err = m.Update(id1, 1, "A small log", []*File{{Name: "app.log", Language: "text", Content: "OK"}}, nil)
+	assert.NilError(t, err)
+	rev, err := m.GetRevision(id1, 1, 0)
+	assert.NilError(t, err)
+	assert.Equal(t, rev.Content, log)
+
+	// Content is only purged once nothing refers to it.
+	_, err = db.Exec(`UPDATE snippet_contents SET used = '2022-01-01 00:00:00'`)
+	assert.NilError(t, err)
+	n, err := m.PurgeContents(10)
+	assert.NilError(t, err)
+	assert.Equal(t, n, int64(0))
+
+	_, err = db.Exec(`DELETE FROM snippets WHERE id IN (?, ?)`, id1, id2)
+	assert.NilError(t, err)
+	n, err = m.PurgeContents(10)
+	assert.NilError(t, err)
+	assert.Equal(t, n, int64(1))
+}
@@ -7,6 +7,10 @@ import (
 var (
 	ErrNoRecord = errors.New("models: no matching record found")
 
+	// ErrBurned is returned when somebody tries to view a burn-after-reading
+	// snippet which has already used up its views.
+	ErrBurned = errors.New("models: snippet has been burned")
+
 	// Add a new ErrInvalidCredentials error. We'll use this later if a user
 	// tries to login with an incorrect email address or password.
 	ErrInvalidCredentials           = errors.New("models: invalid credentials")
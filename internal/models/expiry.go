@@ -0,0 +1,62 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// A snippet's expires column holds the time after which it's no longer
+// available, or NULL if it never expires. unexpiredClause restricts a query
+// to the snippets which haven't expired yet, and should be used by every query
+// which returns snippets to anyone.
+const unexpiredClause = `(expires IS NULL OR expires > UTC_TIMESTAMP())`
+
+// nullTime converts a time into a value for a nullable DATETIME column, with
+// the zero time stored as NULL.
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t.UTC(), Valid: !t.IsZero()}
+}
+
+// SetExpiry changes when a snippet owned by the given user expires. A zero
+// expires means the snippet never expires. Snippets which have already
+// expired can't be brought back this way.
+func (m *SnippetModel) SetExpiry(id int, userID int, expires time.Time) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// MySQL reports an UPDATE which leaves a row unchanged as affecting no
+	// rows, so we check that the snippet exists separately rather than using
+	// execOne().
+	stmt := `SELECT id FROM snippets
+WHERE ` + unexpiredClause + ` AND deleted IS NULL AND id = ? AND user_id = ? FOR UPDATE`
+	err = tx.QueryRow(stmt, id, userID).Scan(&id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNoRecord
+		}
+		return err
+	}
+	_, err = tx.Exec(`UPDATE snippets SET expires = ? WHERE id = ?`, nullTime(expires), id)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DeleteExpired permanently removes up to limit snippets which have expired,
+// along with their revisions and tags, and returns how many were removed.
+// Expired snippets are already hidden by unexpiredClause, so this only
+// reclaims space. Callers wanting to remove the whole backlog should call it
+// again until it returns fewer than limit.
+func (m *SnippetModel) DeleteExpired(limit int) (int64, error) {
+	stmt := `DELETE FROM snippets WHERE expires <= UTC_TIMESTAMP() LIMIT ?`
+	result, err := m.DB.Exec(stmt, limit)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
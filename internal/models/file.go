@@ -0,0 +1,123 @@
+package models
+
+import (
+	"bytes"
+	"database/sql"
+	"strings"
+)
+
+// A File is one of the named files in a snippet. The first file is the
+// snippet's main file: its content and language are also kept in the
+// snippets table, so that listings, search and the single-file views such as
+// raw and download carry on working with a snippet's Content and Language.
+type File struct {
+	ID        int
+	SnippetID int
+	Name      string
+	Language  string
+	Content   string
+	// contentHash is set when the content is large, and stored separately.
+	contentHash []byte
+}
+
+// queryer is implemented by both *sql.DB and *sql.Tx, so that files and
+// content can be loaded inside or outside of a transaction.
+type queryer interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// useMainFile copies the content and language of a snippet's main file onto
+// the snippet itself.
+func useMainFile(s *Snippet) {
+	if len(s.Files) > 0 {
+		s.Content = s.Files[0].Content
+		s.Language = s.Files[0].Language
+	}
+}
+
+// setFiles replaces the files of a snippet inside the transaction tx. The
+// files are stored in the order given.
+func setFiles(tx *sql.Tx, snippetID int, files []*File) error {
+	_, err := tx.Exec(`DELETE FROM snippet_files WHERE snippet_id = ?`, snippetID)
+	if err != nil || len(files) == 0 {
+		return err
+	}
+	args := make([]any, 0, len(files)*6)
+	for i, f := range files {
+		content, hash, err := storeContent(tx, f.Content)
+		if err != nil {
+			return err
+		}
+		args = append(args, snippetID, i, f.Name, f.Language, content, hash)
+	}
+	stmt := `INSERT INTO snippet_files (snippet_id, position, name, language, content, content_hash) VALUES ` +
+		strings.TrimSuffix(strings.Repeat("(?, ?, ?, ?, ?, ?), ", len(files)), ", ")
+	_, err = tx.Exec(stmt, args...)
+	return err
+}
+
+// loadFiles fills in the Files field of a snippet, in order, with their full
+// content. Snippets created before snippets could hold several files have no
+// rows in snippet_files, and are left with no Files.
+func loadFiles(q queryer, s *Snippet) error {
+	stmt := `SELECT id, snippet_id, name, language, content, content_hash FROM snippet_files
+WHERE snippet_id = ? ORDER BY position`
+	files, err := queryFiles(q, stmt, s.ID)
+	if err != nil {
+		return err
+	}
+	s.Files = files
+	return nil
+}
+
+// queryFiles runs a query which selects the id, snippet_id, name, language,
+// content and content_hash of files, and returns them with their full
+// content.
+func queryFiles(q queryer, stmt string, args ...any) ([]*File, error) {
+	rows, err := q.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var files []*File
+	for rows.Next() {
+		f := &File{}
+		err = rows.Scan(&f.ID, &f.SnippetID, &f.Name, &f.Language, &f.Content, &f.contentHash)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	// Load any large content once the rows are closed, since a transaction's
+	// connection can't run another query while it has rows open.
+	rows.Close()
+	for _, f := range files {
+		err = loadContent(q, &f.Content, f.contentHash)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// loadContents fills in the full content and the files of a snippet which
+// has been fetched on its own.
+func loadContents(q queryer, s *Snippet) error {
+	err := loadFiles(q, s)
+	if err != nil {
+		return err
+	}
+	// The snippet's content is a copy of its main file's, so there's no need
+	// to load it twice.
+	if len(s.Files) > 0 && bytes.Equal(s.contentHash, s.Files[0].contentHash) {
+		s.Content = s.Files[0].Content
+		return nil
+	}
+	return loadContent(q, &s.Content, s.contentHash)
+}
@@ -0,0 +1,22 @@
+package models
+
+import (
+	"database/sql"
+)
+
+// nullID converts a row ID into a value for a nullable foreign key column,
+// with 0 stored as NULL.
+func nullID(id int) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(id), Valid: id != 0}
+}
+
+// Forks returns the snippets forked from the given snippet which the viewer
+// may see listed, oldest first: the public ones, plus any of the viewer's own.
+// Like every other listing it leaves out burn-after-reading snippets which
+// belong to somebody else.
+func (m *SnippetModel) Forks(snippetID int, viewerID int) ([]*Snippet, error) {
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets
+WHERE ` + unexpiredClause + ` AND deleted IS NULL AND (` + listedClause + ` OR user_id = ?) AND parent_id = ?
+ORDER BY created, id`
+	return m.querySnippets(stmt, viewerID, snippetID)
+}
@@ -0,0 +1,7 @@
+package mocks
+
+type SessionModel struct{}
+
+func (m *SessionModel) DeleteExpired(limit int) (int64, error) {
+	return 0, nil
+}
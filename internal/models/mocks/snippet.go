@@ -1,32 +1,305 @@
 package mocks
 
 import (
+	"strings"
 	"time"
 
 	"github.com/cipto-hd/snippetbox/internal/models"
 )
 
 var mockSnippet = &models.Snippet{
-	ID:      1,
-	Title:   "An old silent pond",
-	Content: "An old silent pond...",
+	ID:         1,
+	Slug:       "aNoldsilentP",
+	UserID:     1,
+	Title:      "An old silent pond",
+	Content:    "An old silent pond...",
+	Language:   "text",
+	Visibility: models.VisibilityPublic,
+	Tags:       []string{"haiku"},
+	Files: []*models.File{
+		{ID: 1, SnippetID: 1, Name: "pond.txt", Language: "text", Content: "An old silent pond..."},
+		{ID: 2, SnippetID: 1, Name: "frog.go", Language: "go", Content: "frog := \"splash\""},
+	},
+	Created: time.Now(),
+	Updated: time.Now(),
+	Expires: time.Now().Add(7 * 24 * time.Hour),
+}
+
+var mockDeletedSnippet = &models.Snippet{
+	ID:         3,
+	Slug:       "oVerthewintr",
+	UserID:     1,
+	Title:      "Over the wintry forest",
+	Content:    "Over the wintry forest...",
+	Language:   "text",
+	Visibility: models.VisibilityPublic,
+	Created:    time.Now(),
+	Updated:    time.Now(),
+	Expires:    time.Now().Add(7 * 24 * time.Hour),
+	Deleted:    time.Now(),
+}
+
+var mockPrivateSnippet = &models.Snippet{
+	ID:         4,
+	Slug:       "fIrstautumnM",
+	UserID:     1,
+	Title:      "First autumn morning",
+	Content:    "First autumn morning...",
+	Language:   "text",
+	Visibility: models.VisibilityPrivate,
+	Created:    time.Now(),
+	Updated:    time.Now(),
+	Expires:    time.Now().Add(7 * 24 * time.Hour),
+}
+
+var mockBurnSnippet = &models.Snippet{
+	ID:         5,
+	Slug:       "bUrnafterrea",
+	UserID:     1,
+	Title:      "A one-off password",
+	Content:    "hunter2",
+	Language:   "text",
+	Visibility: models.VisibilityUnlisted,
+	MaxViews:   1,
+	Created:    time.Now(),
+	Updated:    time.Now(),
+	Expires:    time.Now().Add(7 * 24 * time.Hour),
+}
+
+var mockFork = &models.Snippet{
+	ID:         6,
+	Slug:       "fOrkofoldpon",
+	UserID:     2,
+	Title:      "An old silent pond (fork)",
+	Content:    "An old silent pond...",
+	Language:   "text",
+	Visibility: models.VisibilityPublic,
+	ParentID:   1,
+	Files: []*models.File{
+		{ID: 3, SnippetID: 6, Name: "pond.txt", Language: "text", Content: "An old silent pond..."},
+		{ID: 4, SnippetID: 6, Name: "frog.go", Language: "go", Content: "frog := \"splash\""},
+	},
+	Created: time.Now(),
+	Updated: time.Now(),
+	Expires: time.Now().Add(7 * 24 * time.Hour),
+}
+
+var mockMarkdownSnippet = &models.Snippet{
+	ID:         7,
+	Slug:       "rEadmeofpond",
+	UserID:     1,
+	Title:      "About the pond",
+	Content:    "# The pond\n\n- [x] frog <script>alert(1)</script>",
+	Language:   "markdown",
+	Visibility: models.VisibilityPublic,
+	Created:    time.Now(),
+	Updated:    time.Now(),
+	Expires:    time.Now().Add(7 * 24 * time.Hour),
+}
+
+const mockBurnedSlug = "bUrnedalread"
+
+var mockRevision = &models.Revision{
+	ID:        1,
+	SnippetID: 1,
+	Number:    1,
+	Title:     "An old pond",
+	Content:   "An old pond...",
+	Language:  "text",
+	Files: []*models.File{
+		{ID: 1, SnippetID: 1, Name: "pond.txt", Language: "text", Content: "An old pond..."},
+		{ID: 2, SnippetID: 1, Name: "frog.go", Language: "go", Content: "frog := \"plop\""},
+		{ID: 3, SnippetID: 1, Name: "NOTES", Language: "text", Content: "Basho, 1686"},
+	},
 	Created: time.Now(),
-	Expires: time.Now(),
 }
 
 type SnippetModel struct{}
 
-func (m *SnippetModel) Insert(title string, content string, expires int) (int, error) {
+func (m *SnippetModel) Insert(s *models.Snippet) (int, error) {
+	s.ID = 2
+	s.Slug = "nEwsnippet02"
 	return 2, nil
 }
-func (m *SnippetModel) Get(id int) (*models.Snippet, error) {
-	switch id {
-	case 1:
+func (m *SnippetModel) Get(id int, viewerID int) (*models.Snippet, error) {
+	switch {
+	case id == 1:
 		return mockSnippet, nil
+	case id == 4 && viewerID == mockPrivateSnippet.UserID:
+		return mockPrivateSnippet, nil
+	case id == 5:
+		return mockBurnSnippet, nil
+	case id == 6:
+		return mockFork, nil
+	case id == 7:
+		return mockMarkdownSnippet, nil
+	default:
+		return nil, models.ErrNoRecord
+	}
+}
+func (m *SnippetModel) GetBySlug(slug string, viewerID int) (*models.Snippet, error) {
+	switch slug {
+	case mockSnippet.Slug:
+		return m.Get(mockSnippet.ID, viewerID)
+	case mockPrivateSnippet.Slug:
+		return m.Get(mockPrivateSnippet.ID, viewerID)
+	case mockFork.Slug:
+		return m.Get(mockFork.ID, viewerID)
+	case mockMarkdownSnippet.Slug:
+		return m.Get(mockMarkdownSnippet.ID, viewerID)
 	default:
 		return nil, models.ErrNoRecord
 	}
 }
-func (m *SnippetModel) Latest() ([]*models.Snippet, error) {
-	return []*models.Snippet{mockSnippet}, nil
+func (m *SnippetModel) View(slug string, viewerID int) (*models.Snippet, error) {
+	switch slug {
+	case mockBurnSnippet.Slug:
+		s := *mockBurnSnippet
+		if viewerID != s.UserID {
+			s.Views++
+		}
+		return &s, nil
+	case mockBurnedSlug:
+		return nil, models.ErrBurned
+	default:
+		return m.GetBySlug(slug, viewerID)
+	}
+}
+func (m *SnippetModel) Latest(q models.PageQuery) (*models.Page, error) {
+	return &models.Page{Snippets: []*models.Snippet{mockSnippet}}, nil
+}
+func (m *SnippetModel) ByAuthor(userID int, q models.PageQuery) (*models.Page, error) {
+	page := &models.Page{Snippets: []*models.Snippet{}}
+	if userID == mockSnippet.UserID {
+		page.Snippets = append(page.Snippets, mockSnippet)
+	}
+	return page, nil
+}
+func (m *SnippetModel) ByUser(userID int) ([]*models.Snippet, error) {
+	switch userID {
+	case 1:
+		return []*models.Snippet{mockSnippet, mockPrivateSnippet}, nil
+	default:
+		return []*models.Snippet{}, nil
+	}
+}
+
+func (m *SnippetModel) Update(id int, userID int, title string, files []*models.File, expires *time.Time) error {
+	if id == 1 && userID == 1 {
+		return nil
+	}
+	return models.ErrNoRecord
+}
+
+func (m *SnippetModel) SetExpiry(id int, userID int, expires time.Time) error {
+	if id == 1 && userID == 1 {
+		return nil
+	}
+	return models.ErrNoRecord
+}
+
+func (m *SnippetModel) Revisions(snippetID int) ([]*models.Revision, error) {
+	switch snippetID {
+	case 1:
+		return []*models.Revision{mockRevision}, nil
+	default:
+		return []*models.Revision{}, nil
+	}
+}
+
+func (m *SnippetModel) GetRevision(snippetID int, number int, viewerID int) (*models.Revision, error) {
+	if snippetID == 1 && number == 1 {
+		return mockRevision, nil
+	}
+	return nil, models.ErrNoRecord
+}
+
+func (m *SnippetModel) Delete(id int, userID int) error {
+	if id == 1 && userID == 1 {
+		return nil
+	}
+	return models.ErrNoRecord
+}
+
+func (m *SnippetModel) Trash(userID int) ([]*models.Snippet, error) {
+	switch userID {
+	case 1:
+		return []*models.Snippet{mockDeletedSnippet}, nil
+	default:
+		return []*models.Snippet{}, nil
+	}
+}
+
+func (m *SnippetModel) Restore(slug string, userID int) error {
+	if slug == mockDeletedSnippet.Slug && userID == 1 {
+		return nil
+	}
+	return models.ErrNoRecord
+}
+
+func (m *SnippetModel) Purge(slug string, userID int) error {
+	if slug == mockDeletedSnippet.Slug && userID == 1 {
+		return nil
+	}
+	return models.ErrNoRecord
+}
+
+func (m *SnippetModel) PurgeTrash(days int, limit int) (int64, error) {
+	return 0, nil
+}
+
+func (m *SnippetModel) DeleteExpired(limit int) (int64, error) {
+	return 0, nil
+}
+
+func (m *SnippetModel) PurgeContents(limit int) (int64, error) {
+	return 0, nil
+}
+
+func (m *SnippetModel) Search(query string, filters models.SearchFilters, page int) (*models.SearchPage, error) {
+	results := []*models.SearchResult{}
+	if strings.Contains(strings.ToLower(mockSnippet.Content), strings.ToLower(query)) &&
+		(filters.UserID == 0 || filters.UserID == mockSnippet.UserID) {
+		results = append(results, &models.SearchResult{Snippet: mockSnippet, Score: 1})
+	}
+	return &models.SearchPage{Results: results, Page: page}, nil
+}
+
+func (m *SnippetModel) ByTag(tag string, q models.PageQuery) (*models.Page, error) {
+	page := &models.Page{Snippets: []*models.Snippet{}}
+	if tag == "haiku" {
+		page.Snippets = append(page.Snippets, mockSnippet)
+	}
+	return page, nil
+}
+
+func (m *SnippetModel) Forks(snippetID int, viewerID int) ([]*models.Snippet, error) {
+	if snippetID == 1 {
+		return []*models.Snippet{mockFork}, nil
+	}
+	return nil, nil
+}
+
+// listedSnippets are the mock snippets which may appear in listings.
+var listedSnippets = []*models.Snippet{mockSnippet, mockFork, mockMarkdownSnippet}
+
+func (m *SnippetModel) MaxNumericID() (int, error) {
+	return 0, nil
+}
+
+func (m *SnippetModel) CountListed() (int, error) {
+	return len(listedSnippets), nil
+}
+
+func (m *SnippetModel) EachListed(offset, limit int, fn func(models.SitemapEntry) error) error {
+	for i, s := range listedSnippets {
+		if i < offset || i >= offset+limit {
+			continue
+		}
+		err := fn(models.SitemapEntry{Slug: s.Slug, Updated: s.Updated})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
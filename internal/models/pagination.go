@@ -0,0 +1,77 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidCursor = errors.New("models: invalid cursor")
+
+// A Cursor marks a position in a list of snippets ordered newest first by
+// (created, id). Because it records the last row seen rather than an offset,
+// pages stay stable when new snippets are inserted between page loads. The
+// zero Cursor means "no position".
+type Cursor struct {
+	Created time.Time
+	ID      int
+}
+
+// IsZero reports whether the cursor is unset.
+func (c Cursor) IsZero() bool {
+	return c.ID == 0
+}
+
+// String encodes the cursor for use in a URL query string.
+func (c Cursor) String() string {
+	if c.IsZero() {
+		return ""
+	}
+	return fmt.Sprintf("%d-%d", c.Created.UnixNano(), c.ID)
+}
+
+// ParseCursor decodes a cursor produced by Cursor.String(). An empty string
+// decodes to the zero Cursor.
+func ParseCursor(s string) (Cursor, error) {
+	if s == "" {
+		return Cursor{}, nil
+	}
+	created, id, ok := strings.Cut(s, "-")
+	if !ok {
+		return Cursor{}, ErrInvalidCursor
+	}
+	nanos, err := strconv.ParseInt(created, 10, 64)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+	n, err := strconv.Atoi(id)
+	if err != nil || n < 1 {
+		return Cursor{}, ErrInvalidCursor
+	}
+	return Cursor{Created: time.Unix(0, nanos).UTC(), ID: n}, nil
+}
+
+// cursorOf returns the cursor pointing at the given snippet.
+func cursorOf(s *Snippet) Cursor {
+	return Cursor{Created: s.Created, ID: s.ID}
+}
+
+// PageQuery selects one page of a list. At most one of Before and After should
+// be set: Before asks for the snippets older than that position, After for the
+// snippets newer than it. If neither is set the newest snippets are returned.
+type PageQuery struct {
+	Before Cursor
+	After  Cursor
+	Limit  int
+}
+
+// Page holds one page of snippets, newest first, along with the cursors for
+// the neighbouring pages. Next points to older snippets and Prev to newer
+// ones; each is the zero Cursor when there is no such page.
+type Page struct {
+	Snippets []*Snippet
+	Next     Cursor
+	Prev     Cursor
+}
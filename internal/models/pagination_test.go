@@ -0,0 +1,51 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cipto-hd/snippetbox/internal/assert"
+)
+
+func TestParseCursor(t *testing.T) {
+	tests := []struct {
+		name    string
+		cursor  string
+		want    Cursor
+		wantErr error
+	}{
+		{
+			name:   "Empty",
+			cursor: "",
+			want:   Cursor{},
+		},
+		{
+			name:   "Round trip",
+			cursor: Cursor{Created: time.Date(2022, 3, 17, 10, 15, 0, 0, time.UTC), ID: 42}.String(),
+			want:   Cursor{Created: time.Date(2022, 3, 17, 10, 15, 0, 0, time.UTC), ID: 42},
+		},
+		{
+			name:    "Missing ID",
+			cursor:  "1647512100000000000",
+			wantErr: ErrInvalidCursor,
+		},
+		{
+			name:    "Zero ID",
+			cursor:  "1647512100000000000-0",
+			wantErr: ErrInvalidCursor,
+		},
+		{
+			name:    "Non-numeric",
+			cursor:  "foo-bar",
+			wantErr: ErrInvalidCursor,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := ParseCursor(tt.cursor)
+			assert.Equal(t, err, tt.wantErr)
+			assert.Equal(t, c.ID, tt.want.ID)
+			assert.Equal(t, c.Created.Equal(tt.want.Created), true)
+		})
+	}
+}
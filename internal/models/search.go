@@ -0,0 +1,105 @@
+package models
+
+// searchPageSize is the number of results returned for each page of a search.
+const searchPageSize = 20
+
+// SearchFilters narrows down a search. The zero value searches every snippet
+// the searcher is allowed to see.
+type SearchFilters struct {
+	// UserID restricts the results to snippets created by this user when it
+	// is non-zero.
+	UserID int
+	// ViewerID is the ID of the user doing the search, or 0 for an anonymous
+	// search. Besides public snippets, the viewer's own unlisted and private
+	// snippets are searched too.
+	ViewerID int
+}
+
+// SearchResult is a snippet matched by a search, along with its relevance
+// score as reported by MySQL. Higher scores are better matches.
+type SearchResult struct {
+	*Snippet
+	Score float64
+}
+
+// SearchPage holds one page of search results, best match first.
+type SearchPage struct {
+	Results []*SearchResult
+	Page    int
+	HasNext bool
+}
+
+// Search looks for snippets whose title or files match the query, using the
+// idx_snippets_fulltext, idx_snippet_files_fulltext and
+// idx_snippet_contents_fulltext FULLTEXT indexes in natural language mode. A
+// snippet scores as well as its best match. Expired and
+// trashed snippets are never returned, in the same way that Get() ignores
+// them, and unlisted and private snippets are only returned to their owner.
+// Pages are numbered from 1.
+func (m *SnippetModel) Search(query string, filters SearchFilters, page int) (*SearchPage, error) {
+	if page < 1 {
+		page = 1
+	}
+
+	// The main file's content is copied into the snippets table, but the
+	// other files are only in snippet_files, and large content only has an
+	// excerpt in either, with the full text in snippet_contents. So matches
+	// in the files and the full text are found separately, and joined back
+	// to their snippets.
+	from := `snippets LEFT JOIN (SELECT snippet_id, MAX(score) AS content_score FROM (
+SELECT snippet_id, MATCH(content) AGAINST (?) AS score FROM snippet_files WHERE MATCH(content) AGAINST (?)
+UNION ALL
+SELECT f.snippet_id, MATCH(c.text) AGAINST (?) FROM snippet_files f JOIN snippet_contents c ON c.hash = f.content_hash
+WHERE MATCH(c.text) AGAINST (?)
+UNION ALL
+SELECT s.id, MATCH(c.text) AGAINST (?) FROM snippets s JOIN snippet_contents c ON c.hash = s.content_hash
+WHERE MATCH(c.text) AGAINST (?)
+) AS matches GROUP BY snippet_id) AS contents ON contents.snippet_id = snippets.id`
+	where := unexpiredClause + ` AND deleted IS NULL AND (` + listedClause + ` OR user_id = ?)
+AND (MATCH(title, content) AGAINST (?) OR content_score IS NOT NULL)`
+	args := []any{query, query, query, query, query, query, query, filters.ViewerID, query}
+	if filters.UserID != 0 {
+		where += ` AND user_id = ?`
+		args = append(args, filters.UserID)
+	}
+	// As with pageSnippets(), fetch one extra row to find out whether there's
+	// another page of results.
+	stmt := `SELECT ` + snippetColumns + `, GREATEST(MATCH(title, content) AGAINST (?), COALESCE(content_score, 0)) AS score
+FROM ` + from + ` WHERE ` + where + ` ORDER BY score DESC, id DESC LIMIT ? OFFSET ?`
+	args = append(args, searchPageSize+1, (page-1)*searchPageSize)
+
+	rows, err := m.DB.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []*SearchResult{}
+	for rows.Next() {
+		r := &SearchResult{}
+		r.Snippet, err = scanSnippet(rows, &r.Score)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, r)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sp := &SearchPage{Results: results, Page: page}
+	if len(results) > searchPageSize {
+		sp.Results = results[:searchPageSize]
+		sp.HasNext = true
+	}
+
+	snippets := make([]*Snippet, len(sp.Results))
+	for i, r := range sp.Results {
+		snippets[i] = r.Snippet
+	}
+	err = m.loadTags(snippets)
+	if err != nil {
+		return nil, err
+	}
+	return sp, nil
+}
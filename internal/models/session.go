@@ -0,0 +1,27 @@
+package models
+
+import (
+	"database/sql"
+)
+
+type SessionModelInterface interface {
+	DeleteExpired(limit int) (int64, error)
+}
+
+// SessionModel works on the sessions table used by the scs MySQL session
+// store. The store reads and writes sessions itself; this model only takes
+// care of housekeeping.
+type SessionModel struct {
+	DB *sql.DB
+}
+
+// DeleteExpired removes up to limit sessions which have expired, and returns
+// how many were removed.
+func (m *SessionModel) DeleteExpired(limit int) (int64, error) {
+	stmt := `DELETE FROM sessions WHERE expiry < UTC_TIMESTAMP(6) LIMIT ?`
+	result, err := m.DB.Exec(stmt, limit)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/cipto-hd/snippetbox/internal/assert"
+)
+
+func TestSessionModelDeleteExpired(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+	_, err := db.Exec(`INSERT INTO sessions (token, data, expiry) VALUES
+('expired', '', DATE_SUB(UTC_TIMESTAMP(6), INTERVAL 1 HOUR)),
+('current', '', DATE_ADD(UTC_TIMESTAMP(6), INTERVAL 1 HOUR))`)
+	assert.NilError(t, err)
+
+	m := SessionModel{db}
+	n, err := m.DeleteExpired(10)
+	assert.NilError(t, err)
+	assert.Equal(t, n, int64(1))
+
+	var token string
+	err = db.QueryRow(`SELECT token FROM sessions`).Scan(&token)
+	assert.NilError(t, err)
+	assert.Equal(t, token, "current")
+}
@@ -0,0 +1,46 @@
+package models
+
+import "time"
+
+// SitemapEntry is what a sitemap needs to know about a snippet: where it
+// lives and when it last changed.
+type SitemapEntry struct {
+	Slug    string
+	Updated time.Time
+}
+
+// CountListed returns the number of snippets which may appear in listings,
+// and so in the sitemap.
+func (m *SnippetModel) CountListed() (int, error) {
+	stmt := `SELECT COUNT(*) FROM snippets WHERE ` + unexpiredClause + ` AND deleted IS NULL AND ` + listedClause
+	var count int
+	err := m.DB.QueryRow(stmt).Scan(&count)
+	return count, err
+}
+
+// EachListed calls fn for up to limit of the snippets which may appear in
+// listings, oldest first, after skipping the first offset of them. Rows are
+// handed over one at a time as they are read, so that a large sitemap can be
+// written out without holding it all in memory. An error from fn stops the
+// iteration and is returned.
+func (m *SnippetModel) EachListed(offset, limit int, fn func(SitemapEntry) error) error {
+	stmt := `SELECT slug, updated FROM snippets WHERE ` + unexpiredClause + ` AND deleted IS NULL AND ` + listedClause + `
+ORDER BY id LIMIT ? OFFSET ?`
+	rows, err := m.DB.Query(stmt, limit, offset)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var e SitemapEntry
+		err = rows.Scan(&e.Slug, &e.Updated)
+		if err != nil {
+			return err
+		}
+		err = fn(e)
+		if err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
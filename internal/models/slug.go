@@ -0,0 +1,53 @@
+package models
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"regexp"
+	"strings"
+)
+
+// slugBytes is the number of random bytes in a slug. Nine bytes encode to
+// exactly twelve base64 characters, with no padding, and give 72 bits of
+// randomness -- far too many to guess or enumerate.
+const slugBytes = 9
+
+// slugRX matches a well-formed slug.
+var slugRX = regexp.MustCompile(`^[A-Za-z0-9_-]{12}$`)
+
+// IsSlug reports whether s looks like a snippet slug. Handlers use it to
+// reject malformed URLs without touching the database.
+func IsSlug(s string) bool {
+	return slugRX.MatchString(s)
+}
+
+// newSlug returns a random, URL-safe slug for a new snippet. Slugs made up
+// entirely of digits are rejected and regenerated, so that a slug can never be
+// mistaken for one of the old numeric IDs.
+func newSlug() (string, error) {
+	b := make([]byte, slugBytes)
+	for {
+		_, err := rand.Read(b)
+		if err != nil {
+			return "", err
+		}
+		slug := base64.RawURLEncoding.EncodeToString(b)
+		if strings.Trim(slug, "0123456789") != "" {
+			return slug, nil
+		}
+	}
+}
+
+// MaxNumericID returns the highest ID of the snippets created before slugs,
+// whose numeric URLs may already have been shared. The migration which added
+// slugs records it, before any snippet is created with a slug, with
+//
+//	INSERT INTO slug_migration (max_snippet_id) SELECT COALESCE(MAX(id), 0) FROM snippets;
+//
+// On a database created with slugs from the start there are no such
+// snippets, and it returns 0.
+func (m *SnippetModel) MaxNumericID() (int, error) {
+	var id int
+	err := m.DB.QueryRow(`SELECT COALESCE(MAX(max_snippet_id), 0) FROM slug_migration`).Scan(&id)
+	return id, err
+}
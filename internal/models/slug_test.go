@@ -0,0 +1,54 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/cipto-hd/snippetbox/internal/assert"
+)
+
+func TestNewSlug(t *testing.T) {
+	seen := map[string]bool{}
+	for i := 0; i < 1000; i++ {
+		slug, err := newSlug()
+		assert.NilError(t, err)
+		assert.Equal(t, IsSlug(slug), true)
+		assert.Equal(t, seen[slug], false)
+		seen[slug] = true
+	}
+}
+
+func TestIsSlug(t *testing.T) {
+	tests := []struct {
+		name string
+		slug string
+		want bool
+	}{
+		{name: "Valid", slug: "aNoldsilentP", want: true},
+		{name: "URL-safe characters", slug: "a-b_c-d_e-f_", want: true},
+		{name: "Too short", slug: "aNoldsilent", want: false},
+		{name: "Too long", slug: "aNoldsilentPo", want: false},
+		{name: "Padding", slug: "aNoldsilen==", want: false},
+		{name: "Empty", slug: "", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, IsSlug(tt.slug), tt.want)
+		})
+	}
+}
+
+func TestSnippetModelMaxNumericID(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+	m := SnippetModel{db}
+	// The test data is set up as if every snippet in it predates slugs, and
+	// new snippets don't change that.
+	_, err := m.Insert(&Snippet{UserID: 1, Title: "New", Content: "New", Visibility: VisibilityUnlisted})
+	assert.NilError(t, err)
+	id, err := m.MaxNumericID()
+	assert.NilError(t, err)
+	assert.Equal(t, id, 4)
+}
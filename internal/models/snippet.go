@@ -3,24 +3,174 @@ package models
 import (
 	"database/sql"
 	"errors"
+	"slices"
+	"strings"
 	"time"
+
+	"github.com/go-sql-driver/mysql"
 )
 
 // Define a Snippet type to hold the data for an individual snippet. Notice how
 // the fields of the struct correspond to the fields in our MySQL snippets
 // table?
 type Snippet struct {
-	ID      int
-	Title   string
-	Content string
-	Created time.Time
-	Expires time.Time
+	ID         int
+	Slug       string
+	UserID     int
+	Title      string
+	Content    string
+	Language   string
+	Visibility Visibility
+	Created    time.Time
+	Updated    time.Time
+	Expires    time.Time // The zero time if the snippet never expires.
+	Deleted    time.Time
+	Tags       []string
+	// MaxViews is the number of times a burn-after-reading snippet can be
+	// viewed before it's destroyed, or 0 if it lives until it expires. Views
+	// counts the views so far and Burned records when it was destroyed.
+	MaxViews int
+	Views    int
+	Burned   time.Time
+	// ParentID is the ID of the snippet this one was forked from, or 0 if it
+	// wasn't forked.
+	ParentID int
+	// Files holds the snippet's named files, main file first. It's only
+	// filled in when a single snippet is fetched.
+	Files []*File
+	// contentHash is set when the content is large, and stored separately.
+	// Only snippets fetched on their own have their full Content; in listings
+	// it's cut short to an excerpt.
+	contentHash []byte
+}
+
+// Define a Revision type to hold a previous version of a snippet. Revisions
+// are numbered from 1 for each snippet, so the (SnippetID, Number) pair gives
+// every revision a stable address.
+type Revision struct {
+	ID        int
+	SnippetID int
+	Number    int
+	Title     string
+	Content   string // Cut short to an excerpt by Revisions() if large.
+	// Language is the main file's language, or empty for revisions saved
+	// before it was kept.
+	Language string
+	Created  time.Time
+	// Files holds copies of the snippet's files as they were, main file
+	// first. It's only filled in by GetRevision(). Revisions saved before
+	// every file was kept have none, only the Content of the main file.
+	Files []*File
+	// contentHash is set when the content is large, and stored separately.
+	contentHash []byte
 }
 
 type SnippetModelInterface interface {
-	Insert(title string, content string, expires int) (int, error)
-	Get(id int) (*Snippet, error)
-	Latest() ([]*Snippet, error)
+	Insert(s *Snippet) (int, error)
+	Get(id int, viewerID int) (*Snippet, error)
+	GetBySlug(slug string, viewerID int) (*Snippet, error)
+	View(slug string, viewerID int) (*Snippet, error)
+	Latest(q PageQuery) (*Page, error)
+	ByUser(userID int) ([]*Snippet, error)
+	ByAuthor(userID int, q PageQuery) (*Page, error)
+	Update(id int, userID int, title string, files []*File, expires *time.Time) error
+	SetExpiry(id int, userID int, expires time.Time) error
+	Revisions(snippetID int) ([]*Revision, error)
+	GetRevision(snippetID int, number int, viewerID int) (*Revision, error)
+	Delete(id int, userID int) error
+	Trash(userID int) ([]*Snippet, error)
+	Restore(slug string, userID int) error
+	Purge(slug string, userID int) error
+	PurgeTrash(days int, limit int) (int64, error)
+	DeleteExpired(limit int) (int64, error)
+	PurgeContents(limit int) (int64, error)
+	Search(query string, filters SearchFilters, page int) (*SearchPage, error)
+	ByTag(tag string, q PageQuery) (*Page, error)
+	Forks(snippetID int, viewerID int) ([]*Snippet, error)
+	CountListed() (int, error)
+	MaxNumericID() (int, error)
+	EachListed(offset, limit int, fn func(SitemapEntry) error) error
+}
+
+// snippetColumns lists the columns scanned by scanSnippet(), in order. Every
+// query which returns whole snippets should select exactly these columns.
+const snippetColumns = `id, slug, user_id, title, content, content_hash, language, visibility, created, updated, expires, deleted,
+max_views, views, burned, parent_id`
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanSnippet copies the snippetColumns of the current row into a new Snippet.
+// Any extra destinations are scanned from the columns which follow them.
+func scanSnippet(row rowScanner, extra ...any) (*Snippet, error) {
+	s := &Snippet{}
+	// The deleted column is NULL for snippets which aren't in the trash, so we
+	// scan it into a sql.NullTime and leave Deleted as the zero time. The
+	// same goes for expires and burned, and for parent_id with sql.NullInt64.
+	var expires, deleted, burned sql.NullTime
+	var parentID sql.NullInt64
+	dest := []any{&s.ID, &s.Slug, &s.UserID, &s.Title, &s.Content, &s.contentHash, &s.Language, &s.Visibility, &s.Created, &s.Updated, &expires, &deleted,
+		&s.MaxViews, &s.Views, &burned, &parentID}
+	err := row.Scan(append(dest, extra...)...)
+	if err != nil {
+		return nil, err
+	}
+	s.Expires = expires.Time
+	s.Deleted = deleted.Time
+	s.Burned = burned.Time
+	s.ParentID = int(parentID.Int64)
+	return s, nil
+}
+
+// querySnippets runs a query which selects snippetColumns and returns every
+// matching row.
+func (m *SnippetModel) querySnippets(stmt string, args ...any) ([]*Snippet, error) {
+	// Use the Query() method on the connection pool to execute our
+	// SQL statement. This returns a sql.Rows resultset containing the result of
+	// our query.
+	rows, err := m.DB.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	// We defer rows.Close() to ensure the sql.Rows resultset is
+	// always properly closed before the method returns. This defer
+	// statement should come *after* you check for an error from the Query()
+	// method. Otherwise, if Query() returns an error, you'll get a panic
+	// trying to close a nil resultset.
+	defer rows.Close()
+	// Initialize an empty slice to hold the Snippet structs.
+	snippets := []*Snippet{}
+	// Use rows.Next to iterate through the rows in the resultset. This
+	// prepares the first (and then each subsequent) row to be acted on by the
+	// rows.Scan() method. If iteration over all the rows completes then the
+	// resultset automatically closes itself and frees-up the underlying
+	// database connection.
+	for rows.Next() {
+		// Use scanSnippet() to copy the values from each field in the row to
+		// a new Snippet object.
+		s, err := scanSnippet(rows)
+		if err != nil {
+			return nil, err
+		}
+		// Append it to the slice of snippets.
+		snippets = append(snippets, s)
+	}
+	// When the rows.Next() loop has finished we call rows.Err() to retrieve any
+	// error that was encountered during the iteration. It's important to
+	// call this - don't assume that a successful iteration was completed
+	// over the whole resultset.
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	// Attach the tags for every snippet in a single extra query.
+	err = m.loadTags(snippets)
+	if err != nil {
+		return nil, err
+	}
+	// If everything went OK then return the Snippets slice.
+	return snippets, nil
 }
 
 // Define a SnippetModel type which wraps a sql.DB connection pool.
@@ -28,19 +178,61 @@ type SnippetModel struct {
 	DB *sql.DB
 }
 
-// This will insert a new snippet into the database.
-func (m *SnippetModel) Insert(title string, content string, expires int) (int, error) {
+// This will insert a new snippet into the database. The owner, title,
+// content, language, visibility, expiry, view limit, parent, tags and files
+// are taken from s; a zero Expires means the snippet never expires. If s has
+// files, its content and language are those of the first one. The snippet,
+// its tags and its files are inserted in a single transaction. On success the
+// new snippet's ID and slug are filled in on s, and the ID is returned.
+func (m *SnippetModel) Insert(s *Snippet) (int, error) {
+	// Slugs are random, so there's a tiny chance of picking one that's already
+	// taken. If that happens the unique constraint on the slug column rejects
+	// the insert and we simply try again with a new slug.
+	for attempt := 1; ; attempt++ {
+		slug, err := newSlug()
+		if err != nil {
+			return 0, err
+		}
+		id, err := m.insert(s, slug)
+		if err != nil {
+			var mySQLError *mysql.MySQLError
+			if attempt < 3 && errors.As(err, &mySQLError) &&
+				mySQLError.Number == 1062 && strings.Contains(mySQLError.Message, "snippets_uc_slug") {
+				continue
+			}
+			return 0, err
+		}
+		s.ID = id
+		s.Slug = slug
+		return id, nil
+	}
+}
+
+// insert does the work for Insert() using the given slug.
+func (m *SnippetModel) insert(s *Snippet, slug string) (int, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	// Rollback() is a no-op once the transaction has been committed.
+	defer tx.Rollback()
+
 	// Write the SQL statement we want to execute. I've split it over two lines
 	// for readability (which is why it's surrounded with backquotes instead
 	// of normal double quotes).
-	stmt := `INSERT INTO snippets (title, content, created, expires)
-VALUES(?, ?, UTC_TIMESTAMP(), DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? DAY))`
-	// Use the Exec() method on the embedded connection pool to execute the
-	// statement. The first parameter is the SQL statement, followed by the
-	// title, content and expiry values for the placeholder parameters. This
-	// method returns a sql.Result type, which contains some basic
-	// information about what happened when the statement was executed.
-	result, err := m.DB.Exec(stmt, title, content, expires)
+	useMainFile(s)
+	content, hash, err := storeContent(tx, s.Content)
+	if err != nil {
+		return 0, err
+	}
+	stmt := `INSERT INTO snippets (slug, user_id, title, content, content_hash, language, visibility, max_views, parent_id, created, updated, expires)
+VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, UTC_TIMESTAMP(), UTC_TIMESTAMP(), ?)`
+	// Use the Exec() method on the transaction to execute the statement. The
+	// first parameter is the SQL statement, followed by the slug and the
+	// snippet's fields for the placeholder parameters. This method returns a
+	// sql.Result type, which contains some basic information about what
+	// happened when the statement was executed.
+	result, err := tx.Exec(stmt, slug, s.UserID, s.Title, content, hash, s.Language, s.Visibility, s.MaxViews, nullID(s.ParentID), nullTime(s.Expires))
 	if err != nil {
 		return 0, err
 	}
@@ -50,30 +242,40 @@ VALUES(?, ?, UTC_TIMESTAMP(), DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? DAY))`
 	if err != nil {
 		return 0, err
 	}
+
+	err = setTags(tx, int(id), s.Tags)
+	if err != nil {
+		return 0, err
+	}
+	err = setFiles(tx, int(id), s.Files)
+	if err != nil {
+		return 0, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
 	// The ID returned has the type int64, so we convert it to an int type
 	// before returning.
 	return int(id), nil
 }
 
-// This will return a specific snippet based on its id.
-func (m *SnippetModel) Get(id int) (*Snippet, error) {
+// This will return a specific snippet based on its id, as seen by the user
+// with the given viewerID (or 0 for an anonymous viewer). Private snippets
+// are only returned to their owner; for anyone else they don't exist.
+func (m *SnippetModel) Get(id int, viewerID int) (*Snippet, error) {
 	// Write the SQL statement we want to execute. Again, I've split it over two
 	// lines for readability.
-	stmt := `SELECT id, title, content, created, expires FROM snippets
-WHERE expires > UTC_TIMESTAMP() AND id = ?`
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets
+WHERE ` + unexpiredClause + ` AND deleted IS NULL AND ` + viewableClause + ` AND id = ?`
 	// Use the QueryRow() method on the connection pool to execute our
-	// SQL statement, passing in the untrusted id variable as the value for the
-	// placeholder parameter. This returns a pointer to a sql.Row object which
-	// holds the result from the database.
-	row := m.DB.QueryRow(stmt, id)
-	// Initialize a pointer to a new zeroed Snippet struct.
-	s := &Snippet{}
-	// Use row.Scan() to copy the values from each field in sql.Row to the
-	// corresponding field in the Snippet struct. Notice that the arguments
-	// to row.Scan are *pointers* to the place you want to copy the data into,
-	// and the number of arguments must be exactly the same as the number of
-	// columns returned by your statement.
-	err := row.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
+	// SQL statement, passing in the viewer and the untrusted id variable as the
+	// values for the placeholder parameters. This returns a pointer to a
+	// sql.Row object which holds the result from the database.
+	row := m.DB.QueryRow(stmt, viewerID, id)
+	// Use scanSnippet() to copy the values from each field in sql.Row to the
+	// corresponding field in a new Snippet struct.
+	s, err := scanSnippet(row)
 	if err != nil {
 		// If the query returns no rows, then row.Scan() will return a
 		// sql.ErrNoRows error. We use the errors.Is() function check for that
@@ -85,57 +287,318 @@ WHERE expires > UTC_TIMESTAMP() AND id = ?`
 			return nil, err
 		}
 	}
+	// Attach the snippet's tags, files and full content.
+	err = m.loadTags([]*Snippet{s})
+	if err != nil {
+		return nil, err
+	}
+	err = loadContents(m.DB, s)
+	if err != nil {
+		return nil, err
+	}
 	// If everything went OK then return the Snippet object.
 	return s, nil
 }
 
-// This will return the 10 most recently created snippets.
-func (m *SnippetModel) Latest() ([]*Snippet, error) {
-	// Write the SQL statement we want to execute.
-	stmt := `SELECT id, title, content, created, expires FROM snippets
-WHERE expires > UTC_TIMESTAMP() ORDER BY id DESC LIMIT 10`
-	// Use the Query() method on the connection pool to execute our
-	// SQL statement. This returns a sql.Rows resultset containing the result of
-	// our query.
-	rows, err := m.DB.Query(stmt)
+// GetBySlug returns a specific snippet based on its public slug, applying the
+// same expiry and visibility rules as Get(). Snippets which have been burned
+// are treated as missing. Unlike View(), GetBySlug() doesn't count as a view of
+// a burn-after-reading snippet, so it's only for showing a snippet to its
+// owner.
+func (m *SnippetModel) GetBySlug(slug string, viewerID int) (*Snippet, error) {
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets
+WHERE ` + unexpiredClause + ` AND deleted IS NULL AND burned IS NULL AND ` + viewableClause + ` AND slug = ?`
+	s, err := scanSnippet(m.DB.QueryRow(stmt, viewerID, slug))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		}
+		return nil, err
+	}
+	err = m.loadTags([]*Snippet{s})
+	if err != nil {
+		return nil, err
+	}
+	err = loadContents(m.DB, s)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// This will return one page of the most recently created public snippets. See
+// PageQuery for how to ask for older or newer pages.
+func (m *SnippetModel) Latest(q PageQuery) (*Page, error) {
+	return m.pageSnippets(unexpiredClause+` AND deleted IS NULL AND `+listedClause, nil, q)
+}
+
+// pageSnippets returns one page of the snippets matching the given WHERE
+// clause, using keyset pagination on (created, id). We fetch one more row
+// than the page size so that we know whether there is a further page in the
+// direction we're moving.
+func (m *SnippetModel) pageSnippets(where string, args []any, q PageQuery) (*Page, error) {
+	if q.Limit < 1 {
+		q.Limit = 10
+	}
+	order := "DESC"
+	switch {
+	case !q.After.IsZero():
+		// Walk forwards (towards newer snippets) from the cursor. The rows
+		// come back oldest first and are reversed below.
+		where += ` AND (created > ? OR (created = ? AND id > ?))`
+		args = append(args, q.After.Created, q.After.Created, q.After.ID)
+		order = "ASC"
+	case !q.Before.IsZero():
+		where += ` AND (created < ? OR (created = ? AND id < ?))`
+		args = append(args, q.Before.Created, q.Before.Created, q.Before.ID)
+	}
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets WHERE ` + where +
+		` ORDER BY created ` + order + `, id ` + order + ` LIMIT ?`
+	args = append(args, q.Limit+1)
+
+	snippets, err := m.querySnippets(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	more := len(snippets) > q.Limit
+	if more {
+		snippets = snippets[:q.Limit]
+	}
+
+	page := &Page{Snippets: snippets}
+	if len(snippets) == 0 {
+		return page, nil
+	}
+	if !q.After.IsZero() {
+		slices.Reverse(snippets)
+		// We came from an older page, so there is always one to go back to.
+		page.Next = cursorOf(snippets[len(snippets)-1])
+		if more {
+			page.Prev = cursorOf(snippets[0])
+		}
+		return page, nil
+	}
+	if more {
+		page.Next = cursorOf(snippets[len(snippets)-1])
+	}
+	if !q.Before.IsZero() {
+		page.Prev = cursorOf(snippets[0])
+	}
+	return page, nil
+}
+
+// ByAuthor returns one page of the most recently created public snippets
+// by the given user. Unlike ByUser(), it's for showing to anyone.
+func (m *SnippetModel) ByAuthor(userID int, q PageQuery) (*Page, error) {
+	return m.pageSnippets(unexpiredClause+` AND deleted IS NULL AND `+listedClause+` AND user_id = ?`, []any{userID}, q)
+}
+
+// ByUser returns every non-expired snippet created by the given user, newest
+// first. Snippets in the trash are left out.
+func (m *SnippetModel) ByUser(userID int) ([]*Snippet, error) {
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets
+WHERE ` + unexpiredClause + ` AND deleted IS NULL AND user_id = ? ORDER BY id DESC`
+	return m.querySnippets(stmt, userID)
+}
+
+// Update replaces the title and files of a snippet owned by the given user,
+// taking its content and language from the first file. The title, main file
+// and other files being replaced are first copied into the
+// snippet_revisions and snippet_revision_files tables under the next
+// revision number, all inside a single transaction. If expires isn't nil,
+// the snippet's expiry is changed in the same transaction, as by SetExpiry().
+// If the snippet doesn't exist, has expired, or belongs to someone else,
+// ErrNoRecord is returned.
+func (m *SnippetModel) Update(id int, userID int, title string, files []*File, expires *time.Time) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	// Rollback() is a no-op once the transaction has been committed.
+	defer tx.Rollback()
+
+	// Lock the snippet row so that concurrent edits are serialized and can't
+	// claim the same revision number.
+	var oldTitle, oldContent, oldLanguage string
+	var oldHash []byte
+	var oldUpdated time.Time
+	stmt := `SELECT title, content, content_hash, language, updated FROM snippets
+WHERE ` + unexpiredClause + ` AND deleted IS NULL AND id = ? AND user_id = ? FOR UPDATE`
+	err = tx.QueryRow(stmt, id, userID).Scan(&oldTitle, &oldContent, &oldHash, &oldLanguage, &oldUpdated)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNoRecord
+		}
+		return err
+	}
+
+	var number int
+	stmt = `SELECT COALESCE(MAX(revision), 0) + 1 FROM snippet_revisions WHERE snippet_id = ?`
+	err = tx.QueryRow(stmt, id).Scan(&number)
+	if err != nil {
+		return err
+	}
+
+	// The revision shares any large content with the version it's a copy of.
+	stmt = `INSERT INTO snippet_revisions (snippet_id, revision, title, content, content_hash, language, created)
+VALUES(?, ?, ?, ?, ?, ?, ?)`
+	result, err := tx.Exec(stmt, id, number, oldTitle, oldContent, oldHash, oldLanguage, oldUpdated)
+	if err != nil {
+		return err
+	}
+	revisionID, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	stmt = `INSERT INTO snippet_revision_files (revision_id, position, name, language, content, content_hash)
+SELECT ?, position, name, language, content, content_hash FROM snippet_files WHERE snippet_id = ?`
+	_, err = tx.Exec(stmt, revisionID, id)
+	if err != nil {
+		return err
+	}
+
+	s := &Snippet{Files: files}
+	useMainFile(s)
+	content, hash, err := storeContent(tx, s.Content)
+	if err != nil {
+		return err
+	}
+	stmt = `UPDATE snippets SET title = ?, content = ?, content_hash = ?, language = ?, updated = UTC_TIMESTAMP() WHERE id = ?`
+	_, err = tx.Exec(stmt, title, content, hash, s.Language, id)
+	if err != nil {
+		return err
+	}
+	err = setFiles(tx, id, files)
+	if err != nil {
+		return err
+	}
+	if expires != nil {
+		_, err = tx.Exec(`UPDATE snippets SET expires = ? WHERE id = ?`, nullTime(*expires), id)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Revisions returns the previous versions of a snippet, newest first.
+func (m *SnippetModel) Revisions(snippetID int) ([]*Revision, error) {
+	stmt := `SELECT id, snippet_id, revision, title, content, COALESCE(language, ''), created FROM snippet_revisions
+WHERE snippet_id = ? ORDER BY revision DESC`
+	rows, err := m.DB.Query(stmt, snippetID)
 	if err != nil {
 		return nil, err
 	}
-	// We defer rows.Close() to ensure the sql.Rows resultset is
-	// always properly closed before the Latest() method returns. This defer
-	// statement should come *after* you check for an error from the Query()
-	// method. Otherwise, if Query() returns an error, you'll get a panic
-	// trying to close a nil resultset.
 	defer rows.Close()
-	// Initialize an empty slice to hold the Snippet structs.
-	snippets := []*Snippet{}
-	// Use rows.Next to iterate through the rows in the resultset. This
-	// prepares the first (and then each subsequent) row to be acted on by the
-	// rows.Scan() method. If iteration over all the rows completes then the
-	// resultset automatically closes itself and frees-up the underlying
-	// database connection.
+
+	revisions := []*Revision{}
 	for rows.Next() {
-		// Create a pointer to a new zeroed Snippet struct.
-		s := &Snippet{}
-		// Use rows.Scan() to copy the values from each field in the row to the
-		// new Snippet object that we created. Again, the arguments to row.Scan()
-		// must be pointers to the place you want to copy the data into, and the
-		// number of arguments must be exactly the same as the number of
-		// columns returned by your statement.
-		err = rows.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
+		rev := &Revision{}
+		err = rows.Scan(&rev.ID, &rev.SnippetID, &rev.Number, &rev.Title, &rev.Content, &rev.Language, &rev.Created)
 		if err != nil {
 			return nil, err
 		}
-		// Append it to the slice of snippets.
-		snippets = append(snippets, s)
+		revisions = append(revisions, rev)
 	}
-	// When the rows.Next() loop has finished we call rows.Err() to retrieve any
-	// error that was encountered during the iteration. It's important to
-	// call this - don't assume that a successful iteration was completed
-	// over the whole resultset.
 	if err = rows.Err(); err != nil {
 		return nil, err
 	}
-	// If everything went OK then return the Snippets slice.
-	return snippets, nil
+	return revisions, nil
+}
+
+// GetRevision returns a single revision of a snippet by its revision number,
+// with its files. Revisions are subject to the same expiry and visibility
+// rules as the snippet itself in Get(), and the revisions of a
+// burn-after-reading snippet are only returned to its owner, since showing
+// them doesn't count a view.
+func (m *SnippetModel) GetRevision(snippetID int, number int, viewerID int) (*Revision, error) {
+	// The clauses only name columns of snippets, so they need no table
+	// prefix.
+	stmt := `SELECT r.id, r.snippet_id, r.revision, r.title, r.content, r.content_hash, COALESCE(r.language, ''), r.created
+FROM snippet_revisions r INNER JOIN snippets s ON s.id = r.snippet_id
+WHERE ` + unexpiredClause + ` AND deleted IS NULL AND ` + viewableClause + ` AND ` + unburnableClause + `
+AND r.snippet_id = ? AND r.revision = ?`
+	rev := &Revision{}
+	err := m.DB.QueryRow(stmt, viewerID, viewerID, snippetID, number).Scan(&rev.ID, &rev.SnippetID, &rev.Number, &rev.Title, &rev.Content, &rev.contentHash, &rev.Language, &rev.Created)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		}
+		return nil, err
+	}
+	err = loadContent(m.DB, &rev.Content, rev.contentHash)
+	if err != nil {
+		return nil, err
+	}
+	stmt = `SELECT f.id, r.snippet_id, f.name, f.language, f.content, f.content_hash
+FROM snippet_revision_files f INNER JOIN snippet_revisions r ON r.id = f.revision_id
+WHERE f.revision_id = ? ORDER BY f.position`
+	rev.Files, err = queryFiles(m.DB, stmt, rev.ID)
+	if err != nil {
+		return nil, err
+	}
+	return rev, nil
+}
+
+// Delete moves a snippet owned by the given user into their trash. Trashed
+// snippets are hidden by Get(), Latest() and ByUser() but can be brought back
+// with Restore() until they are purged.
+func (m *SnippetModel) Delete(id int, userID int) error {
+	stmt := `UPDATE snippets SET deleted = UTC_TIMESTAMP()
+WHERE ` + unexpiredClause + ` AND deleted IS NULL AND id = ? AND user_id = ?`
+	return m.execOne(stmt, id, userID)
+}
+
+// Trash returns the snippets in the given user's trash, most recently deleted
+// first.
+func (m *SnippetModel) Trash(userID int) ([]*Snippet, error) {
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets
+WHERE ` + unexpiredClause + ` AND deleted IS NOT NULL AND user_id = ? ORDER BY deleted DESC`
+	return m.querySnippets(stmt, userID)
+}
+
+// Restore takes a snippet back out of the given user's trash.
+func (m *SnippetModel) Restore(slug string, userID int) error {
+	stmt := `UPDATE snippets SET deleted = NULL
+WHERE ` + unexpiredClause + ` AND deleted IS NOT NULL AND slug = ? AND user_id = ?`
+	return m.execOne(stmt, slug, userID)
+}
+
+// Purge permanently removes a snippet from the given user's trash. Only
+// snippets which are already in the trash can be purged.
+func (m *SnippetModel) Purge(slug string, userID int) error {
+	stmt := `DELETE FROM snippets WHERE deleted IS NOT NULL AND slug = ? AND user_id = ?`
+	return m.execOne(stmt, slug, userID)
+}
+
+// PurgeTrash permanently removes up to limit snippets which have been in the
+// trash for more than the given number of days, and returns how many were
+// removed. Callers wanting to empty out the whole backlog should call it
+// again until it returns fewer than limit.
+func (m *SnippetModel) PurgeTrash(days int, limit int) (int64, error) {
+	stmt := `DELETE FROM snippets
+WHERE deleted IS NOT NULL AND deleted < DATE_SUB(UTC_TIMESTAMP(), INTERVAL ? DAY) LIMIT ?`
+	result, err := m.DB.Exec(stmt, days, limit)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// execOne executes a statement which is expected to change exactly one row,
+// returning ErrNoRecord if it didn't match anything.
+func (m *SnippetModel) execOne(stmt string, args ...any) error {
+	result, err := m.DB.Exec(stmt, args...)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNoRecord
+	}
+	return nil
 }
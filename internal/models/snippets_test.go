@@ -0,0 +1,415 @@
+package models
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cipto-hd/snippetbox/internal/assert"
+)
+
+func TestSnippetModelGet(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	// The setup script creates a public (1), an unlisted (2) and a private (3)
+	// snippet, plus a burn-after-reading snippet (4), all owned by user 1.
+	tests := []struct {
+		name      string
+		snippetID int
+		viewerID  int
+		wantErr   error
+	}{
+		{
+			name:      "Public, anonymous viewer",
+			snippetID: 1,
+			viewerID:  0,
+		},
+		{
+			name:      "Unlisted, anonymous viewer",
+			snippetID: 2,
+			viewerID:  0,
+		},
+		{
+			name:      "Private, anonymous viewer",
+			snippetID: 3,
+			viewerID:  0,
+			wantErr:   ErrNoRecord,
+		},
+		{
+			name:      "Private, other viewer",
+			snippetID: 3,
+			viewerID:  2,
+			wantErr:   ErrNoRecord,
+		},
+		{
+			name:      "Private, owner",
+			snippetID: 3,
+			viewerID:  1,
+		},
+		{
+			name:      "Non-existent ID",
+			snippetID: 5,
+			viewerID:  1,
+			wantErr:   ErrNoRecord,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t)
+			m := SnippetModel{db}
+			s, err := m.Get(tt.snippetID, tt.viewerID)
+			assert.Equal(t, err, tt.wantErr)
+			if tt.wantErr == nil && s != nil {
+				assert.Equal(t, s.ID, tt.snippetID)
+			}
+		})
+	}
+}
+
+func TestSnippetModelGetBySlug(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+	m := SnippetModel{db}
+	s, err := m.GetBySlug("aNoldsilentP", 0)
+	assert.NilError(t, err)
+	assert.Equal(t, s.ID, 1)
+	// The private snippet is only visible to its owner.
+	_, err = m.GetBySlug("fIrstautumnM", 0)
+	assert.Equal(t, err, ErrNoRecord)
+	s, err = m.GetBySlug("fIrstautumnM", 1)
+	assert.NilError(t, err)
+	assert.Equal(t, s.ID, 3)
+}
+
+func TestSnippetModelView(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+	m := SnippetModel{db}
+	// The burn-after-reading snippet can be viewed twice. Views by its owner
+	// don't count.
+	s, err := m.View("bUrnafterrea", 1)
+	assert.NilError(t, err)
+	assert.Equal(t, s.Views, 0)
+	for views := 1; views <= 2; views++ {
+		s, err = m.View("bUrnafterrea", 0)
+		assert.NilError(t, err)
+		assert.Equal(t, s.Views, views)
+		assert.Equal(t, s.Content, "hunter2")
+	}
+	_, err = m.View("bUrnafterrea", 0)
+	assert.Equal(t, err, ErrBurned)
+	_, err = m.View("bUrnafterrea", 1)
+	assert.Equal(t, err, ErrBurned)
+	_, err = m.GetBySlug("bUrnafterrea", 1)
+	assert.Equal(t, err, ErrNoRecord)
+
+	// Ordinary snippets can be viewed any number of times.
+	for i := 0; i < 3; i++ {
+		_, err = m.View("aNoldsilentP", 0)
+		assert.NilError(t, err)
+	}
+}
+
+func TestSnippetModelGetRevision(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+	m := SnippetModel{db}
+	for _, id := range []int{1, 3, 4} {
+		err := m.Update(id, 1, "Edited", []*File{{Name: "notes.txt", Language: "text", Content: "Edited"}}, nil)
+		assert.NilError(t, err)
+	}
+
+	tests := []struct {
+		name      string
+		snippetID int
+		viewerID  int
+		wantErr   error
+	}{
+		{name: "Public", snippetID: 1, viewerID: 0},
+		{name: "Private", snippetID: 3, viewerID: 0, wantErr: ErrNoRecord},
+		{name: "Private to its owner", snippetID: 3, viewerID: 1},
+		// Showing a revision doesn't count a view, so only the owner can.
+		{name: "Burn after reading", snippetID: 4, viewerID: 0, wantErr: ErrNoRecord},
+		{name: "Burn after reading to its owner", snippetID: 4, viewerID: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := m.GetRevision(tt.snippetID, 1, tt.viewerID)
+			assert.Equal(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestSnippetModelSetExpiry(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+	m := SnippetModel{db}
+	// Snippets can be set to never expire, and can be given a new expiry
+	// again afterwards.
+	err := m.SetExpiry(1, 1, time.Time{})
+	assert.NilError(t, err)
+	s, err := m.Get(1, 0)
+	assert.NilError(t, err)
+	assert.Equal(t, s.Expires.IsZero(), true)
+	err = m.SetExpiry(1, 1, time.Time{})
+	assert.NilError(t, err)
+
+	// Once a snippet has expired it's gone.
+	err = m.SetExpiry(1, 1, time.Now().Add(-time.Minute))
+	assert.NilError(t, err)
+	_, err = m.Get(1, 0)
+	assert.Equal(t, err, ErrNoRecord)
+	err = m.SetExpiry(1, 1, time.Now().Add(time.Hour))
+	assert.Equal(t, err, ErrNoRecord)
+
+	// Only the owner can change the expiry.
+	err = m.SetExpiry(2, 2, time.Time{})
+	assert.Equal(t, err, ErrNoRecord)
+}
+
+func TestSnippetModelDeleteExpired(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+	m := SnippetModel{db}
+	n, err := m.DeleteExpired(10)
+	assert.NilError(t, err)
+	assert.Equal(t, n, int64(0))
+
+	err = m.SetExpiry(1, 1, time.Now().Add(-time.Minute))
+	assert.NilError(t, err)
+	err = m.SetExpiry(2, 1, time.Now().Add(-time.Minute))
+	assert.NilError(t, err)
+	// Expired snippets are deleted in batches of the given size.
+	n, err = m.DeleteExpired(1)
+	assert.NilError(t, err)
+	assert.Equal(t, n, int64(1))
+	n, err = m.DeleteExpired(10)
+	assert.NilError(t, err)
+	assert.Equal(t, n, int64(1))
+
+	var count int
+	err = db.QueryRow(`SELECT COUNT(*) FROM snippets`).Scan(&count)
+	assert.NilError(t, err)
+	assert.Equal(t, count, 2)
+}
+
+func TestSnippetModelLatest(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+	m := SnippetModel{db}
+	// Only the public snippet should be listed.
+	page, err := m.Latest(PageQuery{Limit: 10})
+	assert.NilError(t, err)
+	assert.Equal(t, len(page.Snippets), 1)
+	assert.Equal(t, page.Next.IsZero(), true)
+	assert.Equal(t, page.Prev.IsZero(), true)
+}
+
+func TestSnippetModelByAuthor(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+	m := SnippetModel{db}
+	// Only the public snippet should be listed, even to its author.
+	page, err := m.ByAuthor(1, PageQuery{Limit: 10})
+	assert.NilError(t, err)
+	assert.Equal(t, len(page.Snippets), 1)
+	assert.Equal(t, page.Snippets[0].Slug, "aNoldsilentP")
+
+	page, err = m.ByAuthor(2, PageQuery{Limit: 10})
+	assert.NilError(t, err)
+	assert.Equal(t, len(page.Snippets), 0)
+}
+
+func TestSnippetModelListed(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+	m := SnippetModel{db}
+	// Only the public snippet may be listed; the unlisted, private and
+	// burn-after-reading ones never are.
+	count, err := m.CountListed()
+	assert.NilError(t, err)
+	assert.Equal(t, count, 1)
+
+	var slugs []string
+	err = m.EachListed(0, 10, func(e SitemapEntry) error {
+		slugs = append(slugs, e.Slug)
+		return nil
+	})
+	assert.NilError(t, err)
+	assert.Equal(t, len(slugs), 1)
+	assert.Equal(t, slugs[0], "aNoldsilentP")
+
+	slugs = nil
+	err = m.EachListed(1, 10, func(e SitemapEntry) error {
+		slugs = append(slugs, e.Slug)
+		return nil
+	})
+	assert.NilError(t, err)
+	assert.Equal(t, len(slugs), 0)
+}
+
+func TestSnippetModelForks(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+	m := SnippetModel{db}
+	for _, visibility := range []Visibility{VisibilityPublic, VisibilityPrivate} {
+		_, err := m.Insert(&Snippet{
+			UserID:     1,
+			Title:      "An old silent pond (fork)",
+			Content:    "An old silent pond...",
+			Language:   "text",
+			Visibility: visibility,
+			ParentID:   1,
+		})
+		assert.NilError(t, err)
+	}
+
+	fork, err := m.Get(5, 1)
+	assert.NilError(t, err)
+	assert.Equal(t, fork.ParentID, 1)
+	// Forks are filtered by visibility, just like listings.
+	forks, err := m.Forks(1, 0)
+	assert.NilError(t, err)
+	assert.Equal(t, len(forks), 1)
+	forks, err = m.Forks(1, 1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(forks), 2)
+	forks, err = m.Forks(2, 1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(forks), 0)
+}
+
+func TestSnippetModelFiles(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+	m := SnippetModel{db}
+	id, err := m.Insert(&Snippet{
+		UserID:     1,
+		Title:      "A Go program",
+		Visibility: VisibilityPublic,
+		Expires:    time.Now().Add(time.Hour),
+		Files: []*File{
+			{Name: "main.go", Language: "go", Content: "package main"},
+			{Name: "README", Language: "text", Content: "Run it"},
+		},
+	})
+	assert.NilError(t, err)
+
+	// The main file's content and language are kept on the snippet too.
+	s, err := m.Get(id, 0)
+	assert.NilError(t, err)
+	assert.Equal(t, s.Content, "package main")
+	assert.Equal(t, s.Language, "go")
+	assert.Equal(t, len(s.Files), 2)
+	assert.Equal(t, s.Files[1].Name, "README")
+
+	// The expiry can be changed along with the files.
+	never := time.Time{}
+	err = m.Update(id, 1, "A Go program", []*File{
+		{Name: "notes.txt", Language: "text", Content: "No code yet"},
+	}, &never)
+	assert.NilError(t, err)
+	s, err = m.Get(id, 0)
+	assert.NilError(t, err)
+	assert.Equal(t, s.Expires.IsZero(), true)
+	assert.Equal(t, s.Content, "No code yet")
+	assert.Equal(t, s.Language, "text")
+	assert.Equal(t, len(s.Files), 1)
+	assert.Equal(t, s.Files[0].Name, "notes.txt")
+
+	// The revision keeps every file as it was, along with its language.
+	rev, err := m.GetRevision(id, 1, 0)
+	assert.NilError(t, err)
+	assert.Equal(t, rev.Content, "package main")
+	assert.Equal(t, rev.Language, "go")
+	assert.Equal(t, len(rev.Files), 2)
+	assert.Equal(t, rev.Files[0].Name, "main.go")
+	assert.Equal(t, rev.Files[0].Language, "go")
+	assert.Equal(t, rev.Files[1].Name, "README")
+	assert.Equal(t, rev.Files[1].Content, "Run it")
+	assert.Equal(t, rev.Files[1].SnippetID, id)
+
+	// Snippets saved before files existed have none.
+	s, err = m.Get(1, 0)
+	assert.NilError(t, err)
+	assert.Equal(t, len(s.Files), 0)
+}
+
+func TestSnippetModelSearch(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+	m := SnippetModel{db}
+	id, err := m.Insert(&Snippet{
+		UserID:     1,
+		Title:      "A Go program",
+		Visibility: VisibilityPublic,
+		Files: []*File{
+			{Name: "main.go", Language: "go", Content: "package main"},
+			{Name: "README", Language: "text", Content: "Feed the tadpoles daily"},
+		},
+	})
+	assert.NilError(t, err)
+
+	// Snippets are found by their title...
+	sp, err := m.Search("pond", SearchFilters{}, 1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(sp.Results), 1)
+	assert.Equal(t, sp.Results[0].Slug, "aNoldsilentP")
+
+	// ...and by text in any of their files, not just the main one.
+	sp, err = m.Search("tadpoles", SearchFilters{}, 1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(sp.Results), 1)
+	assert.Equal(t, sp.Results[0].ID, id)
+	assert.Equal(t, sp.Results[0].Content, "package main")
+
+	// Large content is searched in full, not just the excerpt kept with it.
+	id, err = m.Insert(&Snippet{
+		UserID:     1,
+		Title:      "A large log",
+		Visibility: VisibilityPublic,
+		Files: []*File{
+			{Name: "app.log", Language: "text", Content: strings.Repeat("INFO all quiet\n", 2000) + "ERROR heron sighted"},
+		},
+	})
+	assert.NilError(t, err)
+	sp, err = m.Search("heron", SearchFilters{}, 1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(sp.Results), 1)
+	assert.Equal(t, sp.Results[0].ID, id)
+}
@@ -0,0 +1,78 @@
+package models
+
+import (
+	"database/sql"
+	"strings"
+)
+
+// setTags attaches the given tags to a snippet inside the transaction tx,
+// creating rows in the tags table for any tags which don't exist yet. The tags
+// are expected to have already been validated and normalized.
+func setTags(tx *sql.Tx, snippetID int, tags []string) error {
+	if len(tags) == 0 {
+		return nil
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(tags)), ", ")
+	args := make([]any, len(tags))
+	for i, tag := range tags {
+		args[i] = tag
+	}
+
+	// INSERT IGNORE skips tags which are already present, relying on the
+	// tags_uc_name unique constraint.
+	stmt := `INSERT IGNORE INTO tags (name) VALUES ` +
+		strings.TrimSuffix(strings.Repeat("(?), ", len(tags)), ", ")
+	_, err := tx.Exec(stmt, args...)
+	if err != nil {
+		return err
+	}
+
+	stmt = `INSERT INTO snippet_tags (snippet_id, tag_id)
+SELECT ?, id FROM tags WHERE name IN (` + placeholders + `)`
+	_, err = tx.Exec(stmt, append([]any{snippetID}, args...)...)
+	return err
+}
+
+// loadTags fills in the Tags field of each of the given snippets using a single
+// query. Tags are sorted alphabetically.
+func (m *SnippetModel) loadTags(snippets []*Snippet) error {
+	if len(snippets) == 0 {
+		return nil
+	}
+	byID := make(map[int]*Snippet, len(snippets))
+	args := make([]any, len(snippets))
+	for i, s := range snippets {
+		byID[s.ID] = s
+		args[i] = s.ID
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(snippets)), ", ")
+
+	stmt := `SELECT st.snippet_id, t.name FROM snippet_tags st
+INNER JOIN tags t ON t.id = st.tag_id
+WHERE st.snippet_id IN (` + placeholders + `) ORDER BY t.name`
+	rows, err := m.DB.Query(stmt, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int
+		var name string
+		err = rows.Scan(&id, &name)
+		if err != nil {
+			return err
+		}
+		s := byID[id]
+		s.Tags = append(s.Tags, name)
+	}
+	return rows.Err()
+}
+
+// ByTag returns one page of the most recently created public snippets
+// carrying the given tag.
+func (m *SnippetModel) ByTag(tag string, q PageQuery) (*Page, error) {
+	where := unexpiredClause + ` AND deleted IS NULL AND ` + listedClause + ` AND id IN (
+SELECT st.snippet_id FROM snippet_tags st INNER JOIN tags t ON t.id = st.tag_id WHERE t.name = ?)`
+	return m.pageSnippets(where, []any{tag}, q)
+}
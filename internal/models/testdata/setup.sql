@@ -1,19 +1,123 @@
 CREATE TABLE snippets (
-    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT, title VARCHAR(100) NOT NULL, content TEXT NOT NULL, created DATETIME NOT NULL, expires DATETIME NOT NULL
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT, slug CHAR(12) NOT NULL, user_id INTEGER NOT NULL, title VARCHAR(100) NOT NULL, content TEXT NOT NULL, content_hash BINARY(32) NULL, language VARCHAR(20) NOT NULL DEFAULT 'text', visibility ENUM('public', 'unlisted', 'private') NOT NULL DEFAULT 'public', created DATETIME NOT NULL, updated DATETIME NOT NULL, expires DATETIME NULL, deleted DATETIME NULL, max_views INTEGER NOT NULL DEFAULT 0, views INTEGER NOT NULL DEFAULT 0, burned DATETIME NULL, parent_id INTEGER NULL
 );
 
+CREATE TABLE snippet_revisions (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT, snippet_id INTEGER NOT NULL, revision INTEGER NOT NULL, title VARCHAR(100) NOT NULL, content TEXT NOT NULL, content_hash BINARY(32) NULL, language VARCHAR(20) NULL, created DATETIME NOT NULL
+);
+
+ALTER TABLE snippet_revisions ADD CONSTRAINT snippet_revisions_uc_revision UNIQUE (snippet_id, revision);
+
+ALTER TABLE snippet_revisions ADD CONSTRAINT snippet_revisions_fk_snippet_id FOREIGN KEY (snippet_id) REFERENCES snippets (id) ON DELETE CASCADE;
+
+CREATE TABLE snippet_files (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT, snippet_id INTEGER NOT NULL, position INTEGER NOT NULL, name VARCHAR(100) NOT NULL, language VARCHAR(20) NOT NULL DEFAULT 'text', content TEXT NOT NULL, content_hash BINARY(32) NULL
+);
+
+ALTER TABLE snippet_files ADD CONSTRAINT snippet_files_uc_position UNIQUE (snippet_id, position);
+
+ALTER TABLE snippet_files ADD CONSTRAINT snippet_files_uc_name UNIQUE (snippet_id, name);
+
+ALTER TABLE snippet_files ADD CONSTRAINT snippet_files_fk_snippet_id FOREIGN KEY (snippet_id) REFERENCES snippets (id) ON DELETE CASCADE;
+
+CREATE TABLE snippet_revision_files (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT, revision_id INTEGER NOT NULL, position INTEGER NOT NULL, name VARCHAR(100) NOT NULL, language VARCHAR(20) NOT NULL DEFAULT 'text', content TEXT NOT NULL, content_hash BINARY(32) NULL
+);
+
+ALTER TABLE snippet_revision_files ADD CONSTRAINT snippet_revision_files_uc_position UNIQUE (revision_id, position);
+
+ALTER TABLE snippet_revision_files ADD CONSTRAINT snippet_revision_files_fk_revision_id FOREIGN KEY (revision_id) REFERENCES snippet_revisions (id) ON DELETE CASCADE;
+
+CREATE TABLE snippet_contents (
+    hash BINARY(32) NOT NULL PRIMARY KEY, size INTEGER NOT NULL, compressed BOOLEAN NOT NULL, data LONGBLOB NOT NULL, text LONGTEXT NOT NULL, used DATETIME NOT NULL
+);
+
+CREATE INDEX idx_snippets_content_hash ON snippets (content_hash);
+
+CREATE INDEX idx_snippet_files_content_hash ON snippet_files (content_hash);
+
+CREATE INDEX idx_snippet_revisions_content_hash ON snippet_revisions (content_hash);
+
+CREATE INDEX idx_snippet_revision_files_content_hash ON snippet_revision_files (content_hash);
+
+CREATE TABLE tags (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT, name VARCHAR(30) NOT NULL
+);
+
+ALTER TABLE tags ADD CONSTRAINT tags_uc_name UNIQUE (name);
+
+CREATE TABLE snippet_tags (
+    snippet_id INTEGER NOT NULL, tag_id INTEGER NOT NULL, PRIMARY KEY (snippet_id, tag_id)
+);
+
+CREATE INDEX idx_snippet_tags_tag_id ON snippet_tags (tag_id);
+
+ALTER TABLE snippet_tags ADD CONSTRAINT snippet_tags_fk_snippet_id FOREIGN KEY (snippet_id) REFERENCES snippets (id) ON DELETE CASCADE;
+
+ALTER TABLE snippet_tags ADD CONSTRAINT snippet_tags_fk_tag_id FOREIGN KEY (tag_id) REFERENCES tags (id);
+
 CREATE INDEX idx_snippets_created ON snippets (created);
 
+ALTER TABLE snippets ADD CONSTRAINT snippets_uc_slug UNIQUE (slug);
+
+CREATE INDEX idx_snippets_user_id ON snippets (user_id);
+
+ALTER TABLE snippets ADD CONSTRAINT snippets_fk_parent_id FOREIGN KEY (parent_id) REFERENCES snippets (id) ON DELETE SET NULL;
+
+CREATE INDEX idx_snippets_deleted ON snippets (deleted);
+
+CREATE INDEX idx_snippets_visibility_created ON snippets (visibility, created);
+
+CREATE FULLTEXT INDEX idx_snippets_fulltext ON snippets (title, content);
+
+CREATE FULLTEXT INDEX idx_snippet_files_fulltext ON snippet_files (content);
+
+CREATE FULLTEXT INDEX idx_snippet_contents_fulltext ON snippet_contents (text);
+
 CREATE TABLE users (
     id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT, name VARCHAR(255) NOT NULL, email VARCHAR(255) NOT NULL, hashed_password CHAR(60) NOT NULL, created DATETIME NOT NULL
 );
 
 ALTER TABLE users ADD CONSTRAINT users_uc_email UNIQUE (email);
 
+CREATE TABLE sessions (
+    token CHAR(43) PRIMARY KEY, data BLOB NOT NULL, expiry TIMESTAMP(6) NOT NULL
+);
+
+CREATE INDEX sessions_expiry_idx ON sessions (expiry);
+
+ALTER TABLE snippets ADD CONSTRAINT snippets_fk_user_id FOREIGN KEY (user_id) REFERENCES users (id);
+
 INSERT INTO
     users (
         name, email, hashed_password, created
     )
 VALUES (
         'Alice Jones', 'alice@example.com', '$2a$12$NuTjWXm3KKntReFwyBVHyuf/to.HEwTy.eS206TNfkGfr6HzGJSWG', '2022-01-01 10:00:00'
-    );
\ No newline at end of file
+    );
+
+INSERT INTO
+    snippets (
+        slug, user_id, title, content, language, visibility, created, updated, expires
+    )
+VALUES (
+        'aNoldsilentP', 1, 'An old silent pond', 'An old silent pond...', 'text', 'public', '2022-01-01 10:00:00', '2022-01-01 10:00:00', '2099-01-01 10:00:00'
+    ),
+    (
+        'oVerthewintr', 1, 'Over the wintry forest', 'Over the wintry forest...', 'text', 'unlisted', '2022-01-01 11:00:00', '2022-01-01 11:00:00', '2099-01-01 10:00:00'
+    ),
+    (
+        'fIrstautumnM', 1, 'First autumn morning', 'First autumn morning...', 'text', 'private', '2022-01-01 12:00:00', '2022-01-01 12:00:00', '2099-01-01 10:00:00'
+    );
+
+INSERT INTO
+    snippets (
+        slug, user_id, title, content, language, visibility, max_views, created, updated, expires
+    )
+VALUES (
+        'bUrnafterrea', 1, 'A one-off password', 'hunter2', 'text', 'unlisted', 2, '2022-01-01 13:00:00', '2022-01-01 13:00:00', '2099-01-01 10:00:00'
+    );
+
+CREATE TABLE slug_migration (max_snippet_id INTEGER NOT NULL);
+
+INSERT INTO slug_migration (max_snippet_id) SELECT COALESCE(MAX(id), 0) FROM snippets;
\ No newline at end of file
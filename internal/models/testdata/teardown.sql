@@ -1,3 +1,19 @@
+DROP TABLE slug_migration;
+
+DROP TABLE snippet_tags;
+
+DROP TABLE tags;
+
+DROP TABLE snippet_revision_files;
+
+DROP TABLE snippet_revisions;
+
+DROP TABLE snippet_files;
+
+DROP TABLE snippets;
+
+DROP TABLE snippet_contents;
+
 DROP TABLE users;
 
-DROP TABLE snippets;
\ No newline at end of file
+DROP TABLE sessions;
\ No newline at end of file
@@ -0,0 +1,37 @@
+package models
+
+// Visibility controls who can find and view a snippet.
+type Visibility string
+
+const (
+	// Public snippets are listed on the home page, tag pages, search results
+	// and feeds, and can be viewed by anyone.
+	VisibilityPublic Visibility = "public"
+	// Unlisted snippets can be viewed by anyone who has the link, but are
+	// never listed.
+	VisibilityUnlisted Visibility = "unlisted"
+	// Private snippets can only be viewed by their owner.
+	VisibilityPrivate Visibility = "private"
+)
+
+// Visibilities lists every visibility level, in the order they are offered on
+// the create form.
+var Visibilities = []Visibility{VisibilityPublic, VisibilityUnlisted, VisibilityPrivate}
+
+// The visibility rules are applied in SQL, so that every query which returns
+// snippets enforces them in the same way. listedClause restricts a query to
+// the snippets which may appear in listings. viewableClause restricts a query
+// to the snippets which a viewer may open, and takes the viewer's user ID (or
+// 0 for an anonymous viewer) as its single placeholder parameter.
+//
+// Burn-after-reading snippets are never listed, whatever their visibility,
+// because listings show part of a snippet's content without counting a view.
+// For the same reason, only their owner can see their content anywhere other
+// than through View(). unburnableClause restricts a query to the snippets
+// which aren't burn-after-reading, or are owned by the viewer, and takes the
+// viewer's user ID as its single placeholder parameter.
+const (
+	listedClause     = `(visibility = 'public' AND max_views = 0)`
+	viewableClause   = `(visibility <> 'private' OR user_id = ?)`
+	unburnableClause = `(max_views = 0 OR user_id = ?)`
+)
@@ -0,0 +1,251 @@
+// Package secrets looks for credentials in text, so that they can be caught
+// before they are published in a snippet. Text is checked a line at a time
+// against a set of rules, each of which recognizes one kind of secret, like a
+// private key or a cloud access key. The built-in rules are in Rules; more
+// can be added in code, or loaded from a file with ParseRules().
+package secrets
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// A Rule recognizes one kind of secret.
+type Rule struct {
+	// Name describes the kind of secret, like "AWS access key ID". It's
+	// shown to the user.
+	Name string
+	// RX matches the secret within a line. If it has a capturing group, the
+	// first group is the secret itself and the rest of the match is context,
+	// like the "password =" before a password.
+	RX *regexp.Regexp
+	// Check, if set, is called with each secret matched by RX and can reject
+	// it, to weed out matches which are clearly not secrets.
+	Check func(secret string) bool
+	// Until, if set, marks the secret as a block which runs on to the next
+	// line it matches. The lines in between aren't checked, so a key isn't
+	// reported once for every line of it.
+	Until *regexp.Regexp
+}
+
+// A Finding is a line which appears to contain a secret.
+type Finding struct {
+	Rule string // The name of the rule which matched.
+	Line int    // The line number, counting from 1.
+	// Text is the line, with the secret masked so that showing it doesn't
+	// show the secret again.
+	Text string
+	// Secret is the secret itself, for telling findings apart. For a block,
+	// it's the lines of the block. It mustn't be shown.
+	Secret string
+}
+
+// maxTextChars is how much of a line is kept in a Finding.
+const maxTextChars = 120
+
+// Rules is the built-in rule set.
+var Rules = []Rule{
+	{
+		// The key is on the lines which follow, so the empty group leaves
+		// the header line unmasked.
+		Name:  "Private key",
+		RX:    regexp.MustCompile(`-----BEGIN (?:[A-Z0-9]+ )*PRIVATE KEY(?: BLOCK)?-----()`),
+		Until: regexp.MustCompile(`-----END (?:[A-Z0-9]+ )*PRIVATE KEY(?: BLOCK)?-----`),
+	},
+	{
+		Name: "AWS access key ID",
+		RX:   regexp.MustCompile(`\b((?:AKIA|ASIA)[0-9A-Z]{16})\b`),
+	},
+	{
+		Name: "AWS secret access key",
+		RX:   regexp.MustCompile(`(?i)aws_?secret_?access_?key["']?\s*[:=]\s*["']?([A-Za-z0-9/+]{40})\b`),
+	},
+	{
+		Name: "Google API key",
+		RX:   regexp.MustCompile(`\b(AIza[0-9A-Za-z_-]{35})`),
+	},
+	{
+		Name: "GitHub token",
+		RX:   regexp.MustCompile(`\b((?:gh[pousr]_[A-Za-z0-9]{36,})|(?:github_pat_[A-Za-z0-9_]{22,}))`),
+	},
+	{
+		Name: "Slack token",
+		RX:   regexp.MustCompile(`\b(xox[abprs]-[A-Za-z0-9-]{10,})`),
+	},
+	{
+		Name: "Stripe secret key",
+		RX:   regexp.MustCompile(`\b([sr]k_live_[0-9A-Za-z]{16,})`),
+	},
+	{
+		Name: "JSON Web Token",
+		RX:   regexp.MustCompile(`\b(eyJ[A-Za-z0-9_-]{8,}\.eyJ[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]{16,})`),
+	},
+	{
+		// A quoted value assigned to a key with a telling name, or an
+		// unquoted one on a line of its own, as in a .env or YAML file.
+		Name:  "Password assignment",
+		RX:    regexp.MustCompile(`(?i)(?:password|passwd|pwd|secret|api_?key|access_?token|auth_?token)\w*["']?\s*(?::=|=>|:|=)\s*["']([^"'\s]{6,})["']|^\s*(?:export\s+)?[\w.-]*(?:password|passwd|pwd|secret|api_?key|token)\w*\s*[:=]\s*([^"'\s#;,]{6,})\s*$`),
+		Check: notPlaceholder,
+	},
+	{
+		Name:  "High-entropy token",
+		RX:    regexp.MustCompile(`(?:^|[^A-Za-z0-9+/_=-])([A-Za-z0-9+/_=-]{32,})`),
+		Check: highEntropy,
+	},
+}
+
+// placeholderRX matches values which stand in for a password rather than
+// being one: references to variables, template fields and the like, and runs
+// of a single character like "********".
+var placeholderRX = regexp.MustCompile(`^(?:\$|%|<|\{|\[|\(|os\.|env\.|process\.env|getenv|None$|null$|nil$|true$|false$)`)
+
+// notPlaceholder reports whether s looks like a real password.
+func notPlaceholder(s string) bool {
+	if placeholderRX.MatchString(s) {
+		return false
+	}
+	first, _ := utf8.DecodeRuneInString(s)
+	return strings.Trim(s, string(first)) != ""
+}
+
+// minEntropy is the Shannon entropy, in bits per character, above which a
+// long token is taken to be random. Hex strings, like hashes and commit IDs,
+// can't get above 4, so they are never reported.
+const minEntropy = 4.2
+
+// highEntropy reports whether s looks random enough to be a key: it has to
+// mix letters and digits and have high entropy.
+func highEntropy(s string) bool {
+	var letters, digits bool
+	for _, r := range s {
+		switch {
+		case r >= '0' && r <= '9':
+			digits = true
+		case r >= 'A' && r <= 'Z', r >= 'a' && r <= 'z':
+			letters = true
+		}
+	}
+	return letters && digits && entropy(s) > minEntropy
+}
+
+// entropy returns the Shannon entropy of s in bits per character.
+func entropy(s string) float64 {
+	counts := map[rune]int{}
+	for _, r := range s {
+		counts[r]++
+	}
+	n := float64(utf8.RuneCountInString(s))
+	var h float64
+	for _, c := range counts {
+		p := float64(c) / n
+		h -= p * math.Log2(p)
+	}
+	return h
+}
+
+// Scan checks content against rules, and returns a Finding for each line
+// which appears to contain a secret, in order. Each line is reported once, for
+// the first rule that matches it.
+func Scan(content string, rules []Rule) []Finding {
+	var findings []Finding
+	var until *regexp.Regexp
+	for i, line := range strings.Split(content, "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if until != nil {
+			if until.MatchString(line) {
+				until = nil
+			} else {
+				findings[len(findings)-1].Secret += line + "\n"
+			}
+			continue
+		}
+		for _, rule := range rules {
+			start, end, ok := match(rule, line)
+			if !ok {
+				continue
+			}
+			findings = append(findings, Finding{
+				Rule:   rule.Name,
+				Line:   i + 1,
+				Text:   mask(line, start, end),
+				Secret: line[start:end],
+			})
+			until = rule.Until
+			break
+		}
+	}
+	return findings
+}
+
+// match returns the position of the first secret in line which rule finds,
+// and whether there was one.
+func match(rule Rule, line string) (int, int, bool) {
+	for _, m := range rule.RX.FindAllStringSubmatchIndex(line, -1) {
+		start, end := m[0], m[1]
+		// Use the first group which took part in the match, if there is
+		// one, since the rest is context.
+		for g := 2; g < len(m); g += 2 {
+			if m[g] >= 0 {
+				start, end = m[g], m[g+1]
+				break
+			}
+		}
+		if rule.Check == nil || rule.Check(line[start:end]) {
+			return start, end, true
+		}
+	}
+	return 0, 0, false
+}
+
+// mask replaces all but the first few characters of the secret at
+// line[start:end] with asterisks, and cuts the line down to maxTextChars
+// around it.
+func mask(line string, start, end int) string {
+	secret := []rune(line[start:end])
+	keep := min(4, len(secret)/4)
+	masked := string(secret[:keep]) + strings.Repeat("*", min(len(secret)-keep, 8))
+	before, after := []rune(line[:start]), []rune(line[end:])
+	room := max(maxTextChars-utf8.RuneCountInString(masked), 0)
+	if len(before) > room/2 {
+		before = append([]rune("…"), before[len(before)-room/2:]...)
+	}
+	if len(after) > room/2 {
+		after = append(after[:room/2:room/2], '…')
+	}
+	return strings.TrimSpace(string(before) + masked + string(after))
+}
+
+// ParseRules reads extra rules, one to a line, each made up of a name and a
+// regular expression separated by a tab, like:
+//
+//	Acme API key	\bacme_[0-9a-f]{32}\b
+//
+// As with the built-in rules, the first capturing group in the expression,
+// if it has one, is taken to be the secret. Blank lines and lines starting
+// with # are ignored.
+func ParseRules(r io.Reader) ([]Rule, error) {
+	var rules []Rule
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, expr, ok := strings.Cut(line, "\t")
+		name, expr = strings.TrimSpace(name), strings.TrimSpace(expr)
+		if !ok || name == "" || expr == "" {
+			return nil, fmt.Errorf("secrets: line %d: want a name and an expression separated by a tab", n)
+		}
+		rx, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("secrets: line %d: %w", n, err)
+		}
+		rules = append(rules, Rule{Name: name, RX: rx})
+	}
+	return rules, sc.Err()
+}
@@ -1,6 +1,7 @@
 package validator
 
 import (
+	"cmp"
 	"regexp"
 	"strings"
 	"unicode/utf8"
@@ -22,6 +23,17 @@ type Validator struct {
 // variable is more performant than re-parsing the pattern each time we need it.
 var EmailRX = regexp.MustCompile("^[\\w!#\\$%&'\\*\\+\\/\\=\\?\\^`\\{\\|\\}~\\-]+(:?\\.[\\w!#\\$%&'\\*\\+\\/\\=\\?\\^`\\{\\|\\}~\\-]+)*@(?:[a-z0-9](?:[a-z0-9\\-]*[a-z0-9])?\\.)+[a-z0-9](?:[a-z0-9\\-]*[a-z0-9])?$")
 
+// TagRX matches a snippet tag: lowercase letters, digits and the characters
+// ".", "+", "_" and "-", starting with a letter or digit. Tags are used in URL
+// paths, so characters like "/" and "#" are deliberately not allowed.
+var TagRX = regexp.MustCompile(`^[a-z0-9][a-z0-9.+_-]*$`)
+
+// FilenameRX matches the name of a file in a snippet: ASCII letters, digits
+// and the characters ".", "_" and "-", not starting with a dot. Names are
+// used in zip archives and as page anchors, so paths and spaces aren't
+// allowed.
+var FilenameRX = regexp.MustCompile(`^[A-Za-z0-9_-][A-Za-z0-9._-]*$`)
+
 // Valid() returns true if the FieldErrors map doesn't contain any entries, also
 // check that the NonFieldErrors slice is empty.
 func (v *Validator) Valid() bool {
@@ -97,3 +109,25 @@ func Matches(value string, rx *regexp.Regexp) bool {
 func (v *Validator) AddNonFieldError(message string) {
 	v.NonFieldErrors = append(v.NonFieldErrors, message)
 }
+
+// InRange() returns true if a value is between min and max inclusive.
+func InRange[T cmp.Ordered](value, min, max T) bool {
+	return value >= min && value <= max
+}
+
+// MaxItems() returns true if a slice contains no more than n items.
+func MaxItems[T any](values []T, n int) bool {
+	return len(values) <= n
+}
+
+// All() returns true if the check function returns true for every value in a
+// slice. It's useful for applying the single-value checks above to each item
+// in a list, for example All(tags, func(t string) bool { return MaxChars(t, 30) }).
+func All[T any](values []T, check func(T) bool) bool {
+	for i := range values {
+		if !check(values[i]) {
+			return false
+		}
+	}
+	return true
+}
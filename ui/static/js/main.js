@@ -5,4 +5,112 @@ for (var i = 0; i < navLinks.length; i++) {
 		link.classList.add("live");
 		break;
 	}
-}
\ No newline at end of file
+}
+// Highlight the line or range of lines named in the URL fragment, like #L10 or
+// #L10-L20, and scroll the first of them into view. Lines in the second and
+// later files of a snippet are prefixed with the file number, like #F2-L10.
+// Shift-clicking a line number extends the selection from the currently
+// highlighted line in the same file.
+var lineRangeRX = /^#(F\d+-)?L(\d+)(?:-L(\d+))?$/;
+
+function highlightLines() {
+	var lines = document.querySelectorAll("pre.code .line");
+	for (var i = 0; i < lines.length; i++) {
+		lines[i].classList.remove("hl");
+	}
+	var match = lineRangeRX.exec(window.location.hash);
+	if (!match) {
+		return;
+	}
+	var prefix = match[1] || "";
+	var from = parseInt(match[2], 10);
+	var to = match[3] ? parseInt(match[3], 10) : from;
+	if (to < from) {
+		var swap = from;
+		from = to;
+		to = swap;
+	}
+	for (var n = from; n <= to; n++) {
+		var line = document.getElementById(prefix + "L" + n);
+		if (line) {
+			line.classList.add("hl");
+		}
+	}
+	var first = document.getElementById(prefix + "L" + from);
+	if (first) {
+		// Lines of a markdown file are only shown with its source.
+		var toggle = first.closest(".file").querySelector(".source-toggle");
+		if (toggle) {
+			toggle.checked = true;
+		}
+		first.scrollIntoView({block: "center"});
+	}
+}
+
+var lineNumbers = document.querySelectorAll("pre.code .ln");
+for (var i = 0; i < lineNumbers.length; i++) {
+	lineNumbers[i].addEventListener("click", function(event) {
+		var match = lineRangeRX.exec(window.location.hash);
+		var clicked = lineRangeRX.exec(this.getAttribute("href"));
+		if (event.shiftKey && match && clicked && match[1] === clicked[1]) {
+			event.preventDefault();
+			window.location.hash = "#" + (match[1] || "") + "L" + match[2] + "-L" + clicked[2];
+		}
+	});
+}
+
+window.addEventListener("hashchange", highlightLines);
+highlightLines();
+
+// Count down to the expiry time of a snippet, in the same format as the
+// countdown template function: the largest two non-zero units.
+var countdowns = document.querySelectorAll(".countdown[data-expires]");
+
+function formatCountdown(ms) {
+	if (ms < 60000) {
+		return ms > 0 ? "less than a minute" : "0 minutes";
+	}
+	var units = [["day", 86400000], ["hour", 3600000], ["minute", 60000]];
+	var parts = [];
+	for (var i = 0; i < units.length; i++) {
+		var n = Math.floor(ms / units[i][1]);
+		ms -= n * units[i][1];
+		if (n == 0) {
+			if (parts.length > 0) {
+				break;
+			}
+			continue;
+		}
+		parts.push(n + " " + units[i][0] + (n == 1 ? "" : "s"));
+		if (parts.length == 2) {
+			break;
+		}
+	}
+	return parts.join(" ");
+}
+
+function updateCountdowns() {
+	for (var i = 0; i < countdowns.length; i++) {
+		var expires = Date.parse(countdowns[i].getAttribute("data-expires"));
+		countdowns[i].textContent = formatCountdown(expires - Date.now());
+	}
+}
+
+if (countdowns.length > 0) {
+	updateCountdowns();
+	setInterval(updateCountdowns, 1000);
+}
+
+// Copy the value of the element named by a copy button's data-copy attribute
+// to the clipboard.
+var copyButtons = document.querySelectorAll("button.copy[data-copy]");
+for (var i = 0; i < copyButtons.length; i++) {
+	copyButtons[i].addEventListener("click", function() {
+		var button = this;
+		var source = document.getElementById(button.getAttribute("data-copy"));
+		source.select();
+		navigator.clipboard.writeText(source.value).then(function() {
+			button.textContent = "Copied";
+		});
+	});
+}